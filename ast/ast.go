@@ -136,6 +136,12 @@ func (es *ExpressionStatement) String() string {
 type Identifier struct {
 	Token token.Token // the token.IDENT token
 	Value string
+
+	// Set by the resolver for names local to an enclosing function: the
+	// number of function scopes to walk out and the slot index there
+	Resolved bool
+	Depth    int
+	Slot     int
 }
 
 // guide to Go compiler that Identifier implements Expression interface
@@ -278,16 +284,16 @@ type WhileExpression struct {
 
 func (we *WhileExpression) expressionNode() {}
 
-// TokenLiteral : returns the ifexpression's token literal
+// TokenLiteral : returns the whileexpression's token literal
 func (we *WhileExpression) TokenLiteral() string {
 	return we.Token.Literal
 }
 
-// String : returns the string form of the ifexpression
+// String : returns the string form of the whileexpression
 func (we *WhileExpression) String() string {
 	var out bytes.Buffer
 
-	out.WriteString("if")
+	out.WriteString("while")
 	out.WriteString(we.Condition.String())
 	out.WriteString(" ")
 	out.WriteString(we.Consequence.String())
@@ -299,6 +305,7 @@ func (we *WhileExpression) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	EndToken   token.Token // the } token
 }
 
 func (bs *BlockStatement) statementNode() {}
@@ -324,6 +331,7 @@ type FunctionLiteral struct {
 	Token      token.Token // the 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Locals     []string // slot names set by the resolver, parameters first
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
@@ -351,10 +359,48 @@ func (fl *FunctionLiteral) String() string {
 	return out.String()
 }
 
+// AttributeExpression : implements the Expression interface
+type AttributeExpression struct {
+	Token  token.Token // The '.' token
+	Object Expression
+	Name   string
+}
+
+func (ae *AttributeExpression) expressionNode() {}
+
+// TokenLiteral : return the attributeexpression's token literal
+func (ae *AttributeExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+// String : return the string form of the attributeexpression
+func (ae *AttributeExpression) String() string {
+	return ae.Object.String() + "." + ae.Name
+}
+
+// AssignExpression : implements the Expression interface
+type AssignExpression struct {
+	Token  token.Token // The '=' token
+	Target *AttributeExpression
+	Value  Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+
+// TokenLiteral : return the assignexpression's token literal
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+
+// String : return the string form of the assignexpression
+func (ae *AssignExpression) String() string {
+	return "(" + ae.Target.String() + " = " + ae.Value.String() + ")"
+}
+
 // CallExpression : implements the Expression interface
 type CallExpression struct {
 	Token     token.Token // The '(' token
-	Function  Expression  // Identifier or FunctionLiteral
+	Function  Expression  // Identifier, FunctionLiteral or AttributeExpression
 	Arguments []Expression
 }
 
@@ -0,0 +1,438 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+// Every node is encoded as an object with its "kind" (the Go type name), its
+// "token" with type, literal and position, and one member per field named
+// after the Go field, holding children as nested node objects. Resolver
+// annotations are not encoded.
+
+// jsonToken : the encoding of a token.Token, without trivia
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line"`
+	Column  int             `json:"column"`
+}
+
+// ToJSON : encode a node and all of its children as JSON
+func ToJSON(node Node) ([]byte, error) {
+	return json.Marshal(encodeNode(node))
+}
+
+// FromJSON : decode a node encoded by ToJSON
+func FromJSON(data []byte) (Node, error) {
+	return decodeNode(data)
+}
+
+func encodeToken(tok token.Token) jsonToken {
+	return jsonToken{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}
+}
+
+func encodeNode(node Node) interface{} {
+	if node == nil || reflect.ValueOf(node).IsNil() {
+		return nil
+	}
+
+	switch node := node.(type) {
+	case *Program:
+		return map[string]interface{}{
+			"kind":       "Program",
+			"statements": encodeStatements(node.Statements),
+		}
+	case *LetStatement:
+		return map[string]interface{}{
+			"kind":  "LetStatement",
+			"token": encodeToken(node.Token),
+			"name":  encodeNode(node.Name),
+			"value": encodeNode(node.Value),
+		}
+	case *ReturnStatement:
+		return map[string]interface{}{
+			"kind":        "ReturnStatement",
+			"token":       encodeToken(node.Token),
+			"returnValue": encodeNode(node.ReturnValue),
+		}
+	case *ExpressionStatement:
+		return map[string]interface{}{
+			"kind":       "ExpressionStatement",
+			"token":      encodeToken(node.Token),
+			"expression": encodeNode(node.Expression),
+		}
+	case *BlockStatement:
+		return map[string]interface{}{
+			"kind":       "BlockStatement",
+			"token":      encodeToken(node.Token),
+			"statements": encodeStatements(node.Statements),
+			"endToken":   encodeToken(node.EndToken),
+		}
+	case *Identifier:
+		return map[string]interface{}{
+			"kind":  "Identifier",
+			"token": encodeToken(node.Token),
+			"value": node.Value,
+		}
+	case *IntegerLiteral:
+		return map[string]interface{}{
+			"kind":  "IntegerLiteral",
+			"token": encodeToken(node.Token),
+			"value": node.Value,
+		}
+	case *Boolean:
+		return map[string]interface{}{
+			"kind":  "Boolean",
+			"token": encodeToken(node.Token),
+			"value": node.Value,
+		}
+	case *PrefixExpression:
+		return map[string]interface{}{
+			"kind":     "PrefixExpression",
+			"token":    encodeToken(node.Token),
+			"operator": node.Operator,
+			"right":    encodeNode(node.Right),
+		}
+	case *InfixExpression:
+		return map[string]interface{}{
+			"kind":     "InfixExpression",
+			"token":    encodeToken(node.Token),
+			"left":     encodeNode(node.Left),
+			"operator": node.Operator,
+			"right":    encodeNode(node.Right),
+		}
+	case *IfExpression:
+		return map[string]interface{}{
+			"kind":        "IfExpression",
+			"token":       encodeToken(node.Token),
+			"condition":   encodeNode(node.Condition),
+			"consequence": encodeNode(node.Consequence),
+			"alternative": encodeNode(node.Alternative),
+		}
+	case *WhileExpression:
+		return map[string]interface{}{
+			"kind":        "WhileExpression",
+			"token":       encodeToken(node.Token),
+			"condition":   encodeNode(node.Condition),
+			"consequence": encodeNode(node.Consequence),
+		}
+	case *FunctionLiteral:
+		params := []interface{}{}
+		for _, param := range node.Parameters {
+			params = append(params, encodeNode(param))
+		}
+		return map[string]interface{}{
+			"kind":       "FunctionLiteral",
+			"token":      encodeToken(node.Token),
+			"parameters": params,
+			"body":       encodeNode(node.Body),
+		}
+	case *AttributeExpression:
+		return map[string]interface{}{
+			"kind":   "AttributeExpression",
+			"token":  encodeToken(node.Token),
+			"object": encodeNode(node.Object),
+			"name":   node.Name,
+		}
+	case *AssignExpression:
+		return map[string]interface{}{
+			"kind":   "AssignExpression",
+			"token":  encodeToken(node.Token),
+			"target": encodeNode(node.Target),
+			"value":  encodeNode(node.Value),
+		}
+	case *CallExpression:
+		args := []interface{}{}
+		for _, arg := range node.Arguments {
+			args = append(args, encodeNode(arg))
+		}
+		return map[string]interface{}{
+			"kind":      "CallExpression",
+			"token":     encodeToken(node.Token),
+			"function":  encodeNode(node.Function),
+			"arguments": args,
+		}
+	}
+
+	return nil
+}
+
+func encodeStatements(stmts []Statement) []interface{} {
+	encoded := []interface{}{}
+	for _, s := range stmts {
+		encoded = append(encoded, encodeNode(s))
+	}
+	return encoded
+}
+
+func decodeNode(data json.RawMessage) (Node, error) {
+	if len(data) == 0 || string(data) == "null" {
+		return nil, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var kind string
+	if err := json.Unmarshal(fields["kind"], &kind); err != nil {
+		return nil, fmt.Errorf("node without kind: %s", data)
+	}
+
+	var tok token.Token
+	if raw, ok := fields["token"]; ok {
+		var err error
+		if tok, err = decodeToken(raw); err != nil {
+			return nil, err
+		}
+	}
+
+	d := &fieldDecoder{fields: fields}
+
+	var node Node
+	switch kind {
+	case "Program":
+		node = &Program{Statements: d.statements("statements")}
+	case "LetStatement":
+		d.require("name", "value")
+		node = &LetStatement{Token: tok, Name: d.identifier("name"), Value: d.expression("value")}
+	case "ReturnStatement":
+		d.require("returnValue")
+		node = &ReturnStatement{Token: tok, ReturnValue: d.expression("returnValue")}
+	case "ExpressionStatement":
+		d.require("expression")
+		node = &ExpressionStatement{Token: tok, Expression: d.expression("expression")}
+	case "BlockStatement":
+		block := &BlockStatement{Token: tok, Statements: d.statements("statements")}
+		if raw, ok := fields["endToken"]; ok && d.err == nil {
+			block.EndToken, d.err = decodeToken(raw)
+		}
+		node = block
+	case "Identifier":
+		ident := &Identifier{Token: tok}
+		d.value("value", &ident.Value)
+		node = ident
+	case "IntegerLiteral":
+		il := &IntegerLiteral{Token: tok}
+		d.value("value", &il.Value)
+		node = il
+	case "Boolean":
+		b := &Boolean{Token: tok}
+		d.value("value", &b.Value)
+		node = b
+	case "PrefixExpression":
+		d.require("right")
+		pe := &PrefixExpression{Token: tok, Right: d.expression("right")}
+		d.value("operator", &pe.Operator)
+		node = pe
+	case "InfixExpression":
+		d.require("left", "right")
+		ie := &InfixExpression{Token: tok, Left: d.expression("left"), Right: d.expression("right")}
+		d.value("operator", &ie.Operator)
+		node = ie
+	case "IfExpression":
+		d.require("condition", "consequence")
+		node = &IfExpression{
+			Token:       tok,
+			Condition:   d.expression("condition"),
+			Consequence: d.block("consequence"),
+			Alternative: d.block("alternative"),
+		}
+	case "WhileExpression":
+		d.require("condition", "consequence")
+		node = &WhileExpression{
+			Token:       tok,
+			Condition:   d.expression("condition"),
+			Consequence: d.block("consequence"),
+		}
+	case "FunctionLiteral":
+		d.require("body")
+		fl := &FunctionLiteral{Token: tok, Parameters: []*Identifier{}}
+		for _, raw := range d.list("parameters") {
+			param := d.decodeIdentifier(raw)
+			if param == nil {
+				d.fail("parameters: missing identifier")
+				continue
+			}
+			for _, seen := range fl.Parameters {
+				if seen.Value == param.Value {
+					d.fail("parameters: duplicate parameter %s", param.Value)
+				}
+			}
+			fl.Parameters = append(fl.Parameters, param)
+		}
+		fl.Body = d.block("body")
+		node = fl
+	case "AttributeExpression":
+		d.require("object")
+		ae := &AttributeExpression{Token: tok, Object: d.expression("object")}
+		d.value("name", &ae.Name)
+		node = ae
+	case "AssignExpression":
+		d.require("target", "value")
+		node = &AssignExpression{Token: tok, Target: d.attribute("target"), Value: d.expression("value")}
+	case "CallExpression":
+		d.require("function")
+		ce := &CallExpression{Token: tok, Function: d.expression("function"), Arguments: []Expression{}}
+		for _, raw := range d.list("arguments") {
+			if arg := d.decodeExpression(raw); arg != nil {
+				ce.Arguments = append(ce.Arguments, arg)
+			} else {
+				d.fail("arguments: missing expression")
+			}
+		}
+		node = ce
+	default:
+		return nil, fmt.Errorf("unknown node kind: %q", kind)
+	}
+
+	if d.err != nil {
+		return nil, fmt.Errorf("%s: %s", kind, d.err)
+	}
+	return node, nil
+}
+
+func decodeToken(data json.RawMessage) (token.Token, error) {
+	var tok jsonToken
+	if err := json.Unmarshal(data, &tok); err != nil {
+		return token.Token{}, err
+	}
+	return token.Token{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column}, nil
+}
+
+// fieldDecoder : decodes the members of one node object, keeping the first
+// error so that a node can be assembled in one expression
+type fieldDecoder struct {
+	fields map[string]json.RawMessage
+	err    error
+}
+
+// require : fail unless every named child is present and not null, since a
+// tree missing them cannot be formatted, compiled or evaluated
+func (d *fieldDecoder) require(names ...string) {
+	for _, name := range names {
+		if raw, ok := d.fields[name]; !ok || string(raw) == "null" {
+			d.fail("missing %s", name)
+			return
+		}
+	}
+}
+
+func (d *fieldDecoder) value(name string, v interface{}) {
+	if d.err != nil {
+		return
+	}
+	if err := json.Unmarshal(d.fields[name], v); err != nil {
+		d.err = fmt.Errorf("%s: %s", name, err)
+	}
+}
+
+func (d *fieldDecoder) list(name string) []json.RawMessage {
+	var items []json.RawMessage
+	if raw, ok := d.fields[name]; ok && d.err == nil {
+		if err := json.Unmarshal(raw, &items); err != nil {
+			d.err = fmt.Errorf("%s: %s", name, err)
+		}
+	}
+	return items
+}
+
+func (d *fieldDecoder) node(raw json.RawMessage) Node {
+	if d.err != nil {
+		return nil
+	}
+	node, err := decodeNode(raw)
+	if err != nil {
+		d.err = err
+	}
+	return node
+}
+
+func (d *fieldDecoder) statements(name string) []Statement {
+	stmts := []Statement{}
+	for _, raw := range d.list(name) {
+		node := d.node(raw)
+		if node == nil {
+			d.fail("%s: missing statement", name)
+			return stmts
+		}
+		stmt, ok := node.(Statement)
+		if !ok {
+			d.fail("%s: expected a statement, got %T", name, node)
+			return stmts
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+func (d *fieldDecoder) expression(name string) Expression {
+	return d.decodeExpression(d.fields[name])
+}
+
+func (d *fieldDecoder) decodeExpression(raw json.RawMessage) Expression {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+	exp, ok := node.(Expression)
+	if !ok {
+		d.fail("expected an expression, got %T", node)
+		return nil
+	}
+	return exp
+}
+
+func (d *fieldDecoder) identifier(name string) *Identifier {
+	return d.decodeIdentifier(d.fields[name])
+}
+
+func (d *fieldDecoder) decodeIdentifier(raw json.RawMessage) *Identifier {
+	node := d.node(raw)
+	if node == nil {
+		return nil
+	}
+	ident, ok := node.(*Identifier)
+	if !ok {
+		d.fail("expected an identifier, got %T", node)
+		return nil
+	}
+	return ident
+}
+
+func (d *fieldDecoder) attribute(name string) *AttributeExpression {
+	node := d.node(d.fields[name])
+	if node == nil {
+		return nil
+	}
+	attr, ok := node.(*AttributeExpression)
+	if !ok {
+		d.fail("%s: expected an attribute expression, got %T", name, node)
+		return nil
+	}
+	return attr
+}
+
+func (d *fieldDecoder) block(name string) *BlockStatement {
+	node := d.node(d.fields[name])
+	if node == nil {
+		return nil
+	}
+	block, ok := node.(*BlockStatement)
+	if !ok {
+		d.fail("%s: expected a block statement, got %T", name, node)
+		return nil
+	}
+	return block
+}
+
+func (d *fieldDecoder) fail(format string, a ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, a...)
+	}
+}
@@ -0,0 +1,76 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+func TestToJSON(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		exprStmt(&PrefixExpression{Token: token.Token{Type: token.MINUS, Literal: "-"}, Operator: "-", Right: integer(5)}),
+	}}
+
+	data, err := ToJSON(program)
+	if err != nil {
+		t.Fatalf("ToJSON returned error: %s", err)
+	}
+
+	expected := `{"kind":"Program","statements":[{"expression":{"kind":"PrefixExpression",` +
+		`"operator":"-","right":{"kind":"IntegerLiteral","token":{"type":"INT","literal":"5","line":0,"column":0},"value":5},` +
+		`"token":{"type":"-","literal":"-","line":0,"column":0}},"kind":"ExpressionStatement",` +
+		`"token":{"type":"","literal":"","line":0,"column":0}}]}`
+	if string(data) != expected {
+		t.Errorf("ToJSON wrong.\nexpected=%s\ngot=%s", expected, data)
+	}
+}
+
+func TestFromJSONErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"statements":[]}`, "node without kind"},
+		{`{"kind":"Loop"}`, `unknown node kind: "Loop"`},
+		{`{"kind":"Program","statements":[{"kind":"Identifier","value":"x"}]}`,
+			"expected a statement, got *ast.Identifier"},
+		{`{"kind":"Program","statements":[null]}`, "Program: statements: missing statement"},
+		{`{"kind":"BlockStatement","statements":[{"kind":"ExpressionStatement","expression":{"kind":"Boolean","value":true}},null]}`,
+			"BlockStatement: statements: missing statement"},
+		{`{"kind":"LetStatement","name":null,"value":{"kind":"IntegerLiteral","value":1}}`,
+			"LetStatement: missing name"},
+		{`{"kind":"ExpressionStatement","expression":null}`, "ExpressionStatement: missing expression"},
+		{`{"kind":"LetStatement","name":{"kind":"Boolean","value":true},"value":{"kind":"IntegerLiteral","value":1}}`,
+			"expected an identifier, got *ast.Boolean"},
+		{`{"kind":"IntegerLiteral","value":"five"}`, "IntegerLiteral: value"},
+		{`{"kind":"IfExpression","condition":{"kind":"Boolean","value":true},"consequence":{"kind":"Identifier","value":"x"}}`,
+			"consequence: expected a block statement"},
+		{`{"kind":"IfExpression","consequence":{"kind":"BlockStatement","statements":[]}}`,
+			"IfExpression: missing condition"},
+		{`{"kind":"IfExpression","condition":null,"consequence":{"kind":"BlockStatement","statements":[]}}`,
+			"IfExpression: missing condition"},
+		{`{"kind":"CallExpression","arguments":[]}`, "CallExpression: missing function"},
+		{`{"kind":"CallExpression","function":{"kind":"Identifier","value":"f"},"arguments":[null]}`,
+			"CallExpression: arguments: missing expression"},
+		{`{"kind":"InfixExpression","operator":"+","left":{"kind":"IntegerLiteral","value":1}}`,
+			"InfixExpression: missing right"},
+		{`{"kind":"FunctionLiteral","parameters":[]}`, "FunctionLiteral: missing body"},
+		{`{"kind":"FunctionLiteral","parameters":[{"kind":"Identifier","value":"a"},{"kind":"Identifier","value":"a"}],"body":{"kind":"BlockStatement","statements":[]}}`,
+			"FunctionLiteral: parameters: duplicate parameter a"},
+		{`{"kind":"AssignExpression","value":{"kind":"IntegerLiteral","value":1}}`,
+			"AssignExpression: missing target"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromJSON([]byte(tt.input))
+		if err == nil {
+			t.Errorf("FromJSON(%s) expected an error", tt.input)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("FromJSON(%s) error wrong. expected to contain %q, got=%q",
+				tt.input, tt.expected, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package ast
+
+// ModifierFunc : returns the replacement for a node whose children have
+// already been modified
+type ModifierFunc func(Node) Node
+
+// Modify : rebuild an ast bottom-up, replacing every node with the result of
+// modifier. A replacement of the wrong type for its position, e.g. a
+// statement where an expression is expected, leaves the child unchanged.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		for i, s := range n.Statements {
+			n.Statements[i] = modifyStatement(s, modifier)
+		}
+
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = modifyExpression(n.Value, modifier)
+
+	case *ReturnStatement:
+		n.ReturnValue = modifyExpression(n.ReturnValue, modifier)
+
+	case *ExpressionStatement:
+		n.Expression = modifyExpression(n.Expression, modifier)
+
+	case *BlockStatement:
+		for i, s := range n.Statements {
+			n.Statements[i] = modifyStatement(s, modifier)
+		}
+
+	case *PrefixExpression:
+		n.Right = modifyExpression(n.Right, modifier)
+
+	case *InfixExpression:
+		n.Left = modifyExpression(n.Left, modifier)
+		n.Right = modifyExpression(n.Right, modifier)
+
+	case *IfExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		n.Alternative = modifyBlock(n.Alternative, modifier)
+
+	case *WhileExpression:
+		n.Condition = modifyExpression(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+
+	case *FunctionLiteral:
+		for i, p := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(p, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *AttributeExpression:
+		n.Object = modifyExpression(n.Object, modifier)
+
+	case *AssignExpression:
+		if n.Target != nil {
+			if target, ok := modifyExpression(n.Target, modifier).(*AttributeExpression); ok {
+				n.Target = target
+			}
+		}
+		n.Value = modifyExpression(n.Value, modifier)
+
+	case *CallExpression:
+		n.Function = modifyExpression(n.Function, modifier)
+		for i, a := range n.Arguments {
+			n.Arguments[i] = modifyExpression(a, modifier)
+		}
+	}
+
+	return modifier(node)
+}
+
+func modifyStatement(s Statement, modifier ModifierFunc) Statement {
+	if s == nil {
+		return nil
+	}
+	if modified, ok := Modify(s, modifier).(Statement); ok {
+		return modified
+	}
+	return s
+}
+
+func modifyExpression(e Expression, modifier ModifierFunc) Expression {
+	if e == nil {
+		return nil
+	}
+	if modified, ok := Modify(e, modifier).(Expression); ok {
+		return modified
+	}
+	return e
+}
+
+func modifyIdentifier(i *Identifier, modifier ModifierFunc) *Identifier {
+	if i == nil {
+		return nil
+	}
+	if modified, ok := Modify(i, modifier).(*Identifier); ok {
+		return modified
+	}
+	return i
+}
+
+func modifyBlock(b *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if b == nil {
+		return nil
+	}
+	if modified, ok := Modify(b, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return b
+}
@@ -0,0 +1,100 @@
+package ast
+
+// Visitor : the Visit method is called for every node encountered by Walk.
+// If the result visitor w is not nil, Walk visits each of the children of
+// node with w, followed by a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk : traverse an ast in depth-first order, starting with a call of
+// v.Visit(node)
+func Walk(node Node, v Visitor) {
+	if node == nil {
+		return
+	}
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		for _, s := range n.Statements {
+			Walk(s, v)
+		}
+
+	case *LetStatement:
+		Walk(n.Name, v)
+		Walk(n.Value, v)
+
+	case *ReturnStatement:
+		Walk(n.ReturnValue, v)
+
+	case *ExpressionStatement:
+		Walk(n.Expression, v)
+
+	case *BlockStatement:
+		for _, s := range n.Statements {
+			Walk(s, v)
+		}
+
+	case *Identifier, *IntegerLiteral, *Boolean:
+		// no children
+
+	case *PrefixExpression:
+		Walk(n.Right, v)
+
+	case *InfixExpression:
+		Walk(n.Left, v)
+		Walk(n.Right, v)
+
+	case *IfExpression:
+		Walk(n.Condition, v)
+		Walk(n.Consequence, v)
+		if n.Alternative != nil {
+			Walk(n.Alternative, v)
+		}
+
+	case *WhileExpression:
+		Walk(n.Condition, v)
+		Walk(n.Consequence, v)
+
+	case *FunctionLiteral:
+		for _, p := range n.Parameters {
+			Walk(p, v)
+		}
+		Walk(n.Body, v)
+
+	case *AttributeExpression:
+		Walk(n.Object, v)
+
+	case *AssignExpression:
+		Walk(n.Target, v)
+		Walk(n.Value, v)
+
+	case *CallExpression:
+		Walk(n.Function, v)
+		for _, a := range n.Arguments {
+			Walk(a, v)
+		}
+	}
+
+	v.Visit(nil)
+}
+
+// inspector : adapts a function to the Visitor interface
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect : traverse an ast in depth-first order, calling f for every node
+// and skipping the children of nodes for which f returns false. f is called
+// with nil after the children of a node have been visited.
+func Inspect(node Node, f func(Node) bool) {
+	Walk(node, inspector(f))
+}
@@ -0,0 +1,209 @@
+package ast
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.IDENT, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	literal := fmt.Sprintf("%d", value)
+	return &IntegerLiteral{Token: token.Token{Type: token.INT, Literal: literal}, Value: value}
+}
+
+func block(stmts ...Statement) *BlockStatement {
+	return &BlockStatement{Token: token.Token{Type: token.LBRACE, Literal: "{"}, Statements: stmts}
+}
+
+func exprStmt(e Expression) *ExpressionStatement {
+	return &ExpressionStatement{Expression: e}
+}
+
+// allNodesProgram : a program containing every node type, equivalent to
+//
+//	let f = fn(a, b) { return -a + b; };
+//	while (true) { if (x) { f(1, 2) } else { y } }
+func allNodesProgram() *Program {
+	return &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Token: token.Token{Type: token.LET, Literal: "let"},
+				Name:  ident("f"),
+				Value: &FunctionLiteral{
+					Token:      token.Token{Type: token.FUNCTION, Literal: "fn"},
+					Parameters: []*Identifier{ident("a"), ident("b")},
+					Body: block(&ReturnStatement{
+						Token: token.Token{Type: token.RETURN, Literal: "return"},
+						ReturnValue: &InfixExpression{
+							Left:     &PrefixExpression{Operator: "-", Right: ident("a")},
+							Operator: "+",
+							Right:    ident("b"),
+						},
+					}),
+				},
+			},
+			exprStmt(&WhileExpression{
+				Condition: &Boolean{Token: token.Token{Type: token.TRUE, Literal: "true"}, Value: true},
+				Consequence: block(exprStmt(&IfExpression{
+					Condition: ident("x"),
+					Consequence: block(exprStmt(&CallExpression{
+						Function:  ident("f"),
+						Arguments: []Expression{integer(1), integer(2)},
+					})),
+					Alternative: block(exprStmt(ident("y"))),
+				})),
+			}),
+		},
+	}
+}
+
+// describe : return a short description of a node for visit traces
+func describe(node Node) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	switch node := node.(type) {
+	case *Identifier:
+		return name + " " + node.Value
+	case *IntegerLiteral:
+		return name + " " + node.Token.Literal
+	}
+	return name
+}
+
+type recorder struct {
+	visited []string
+}
+
+func (r *recorder) Visit(node Node) Visitor {
+	if node == nil {
+		r.visited = append(r.visited, "end")
+		return nil
+	}
+	r.visited = append(r.visited, describe(node))
+	return r
+}
+
+func TestWalk(t *testing.T) {
+	r := &recorder{}
+	Walk(allNodesProgram(), r)
+
+	var entered []string
+	ends := 0
+	for _, v := range r.visited {
+		if v == "end" {
+			ends++
+		} else {
+			entered = append(entered, v)
+		}
+	}
+
+	expected := []string{
+		"Program",
+		"LetStatement", "Identifier f",
+		"FunctionLiteral", "Identifier a", "Identifier b",
+		"BlockStatement", "ReturnStatement",
+		"InfixExpression", "PrefixExpression", "Identifier a", "Identifier b",
+		"ExpressionStatement", "WhileExpression", "Boolean",
+		"BlockStatement", "ExpressionStatement",
+		"IfExpression", "Identifier x",
+		"BlockStatement", "ExpressionStatement",
+		"CallExpression", "Identifier f", "IntegerLiteral 1", "IntegerLiteral 2",
+		"BlockStatement", "ExpressionStatement", "Identifier y",
+	}
+
+	if strings.Join(entered, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("wrong visit order.\nwant=%v\ngot =%v", expected, entered)
+	}
+
+	if ends != len(expected) {
+		t.Errorf("wrong number of end visits. want=%d, got=%d", len(expected), ends)
+	}
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	var visited []string
+	Inspect(allNodesProgram(), func(node Node) bool {
+		if node == nil {
+			return false
+		}
+		visited = append(visited, describe(node))
+		_, isFunction := node.(*FunctionLiteral)
+		_, isWhile := node.(*WhileExpression)
+		return !isFunction && !isWhile
+	})
+
+	expected := []string{
+		"Program", "LetStatement", "Identifier f", "FunctionLiteral",
+		"ExpressionStatement", "WhileExpression",
+	}
+
+	if strings.Join(visited, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("wrong visit order.\nwant=%v\ngot =%v", expected, visited)
+	}
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+
+	turnOneIntoTwo := func(node Node) Node {
+		integer, ok := node.(*IntegerLiteral)
+		if !ok || integer.Value != 1 {
+			return node
+		}
+		return two()
+	}
+	renameToZ := func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return ident("z")
+		}
+		return node
+	}
+
+	tests := []struct {
+		input    Node
+		modifier ModifierFunc
+		expected string
+	}{
+		{&Program{Statements: []Statement{exprStmt(one())}}, turnOneIntoTwo, "2"},
+		{&InfixExpression{Left: one(), Operator: "+", Right: one()}, turnOneIntoTwo, "(2 + 2)"},
+		{&PrefixExpression{Operator: "-", Right: one()}, turnOneIntoTwo, "(-2)"},
+		{&ReturnStatement{Token: token.Token{Literal: "return"}, ReturnValue: one()}, turnOneIntoTwo, "return 2;"},
+		{&LetStatement{Token: token.Token{Literal: "let"}, Name: ident("a"), Value: one()}, turnOneIntoTwo, "let a = 2;"},
+		{&LetStatement{Token: token.Token{Literal: "let"}, Name: ident("a"), Value: ident("b")}, renameToZ, "let z = z;"},
+		{&IfExpression{Condition: one(), Consequence: block(exprStmt(one())), Alternative: block(exprStmt(one()))},
+			turnOneIntoTwo, "if2 2else 2"},
+		{&WhileExpression{Condition: one(), Consequence: block(exprStmt(one()))}, turnOneIntoTwo, "while2 2"},
+		{&FunctionLiteral{Token: token.Token{Literal: "fn"}, Parameters: []*Identifier{ident("a"), ident("b")},
+			Body: block(exprStmt(ident("a")))}, renameToZ, "fn(z, z)z"},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{one(), ident("x")}}, renameToZ, "z(1, z)"},
+		{&CallExpression{Function: ident("f"), Arguments: []Expression{one(), one()}}, turnOneIntoTwo, "f(2, 2)"},
+	}
+
+	for _, tt := range tests {
+		modified := Modify(tt.input, tt.modifier)
+		if modified.String() != tt.expected {
+			t.Errorf("wrong modification. want=%q, got=%q", tt.expected, modified.String())
+		}
+	}
+}
+
+func TestModifyKeepsChildOnWrongType(t *testing.T) {
+	program := &Program{Statements: []Statement{exprStmt(integer(1))}}
+
+	Modify(program, func(node Node) Node {
+		if _, ok := node.(*IntegerLiteral); ok {
+			return block() // not an expression
+		}
+		return node
+	})
+
+	if program.String() != "1" {
+		t.Errorf("child replaced with a node of the wrong type. got=%q", program.String())
+	}
+}
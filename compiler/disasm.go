@@ -0,0 +1,101 @@
+package compiler
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/rockspore/monkey-interpreter/code"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+// Disassemble : return a listing of the main program followed by every
+// function constant it references, directly or through nested functions.
+// Each instruction is printed with its offset, source line, operands and the
+// constant its operand refers to.
+func Disassemble(bytecode *Bytecode) string {
+	var out bytes.Buffer
+
+	d := &disassembler{out: &out, constants: bytecode.Constants, seen: map[int]bool{}}
+
+	fmt.Fprintf(&out, "== main ==\n")
+	pending := d.function(bytecode.Instructions, bytecode.Lines)
+
+	// functions are listed in the order they are first referenced
+	for len(pending) > 0 {
+		index := pending[0]
+		pending = pending[1:]
+
+		fn := bytecode.Constants[index].(*object.CompiledFunction)
+		fmt.Fprintf(&out, "\n== constant %d: fn(parameters=%d, locals=%d) ==\n",
+			index, fn.NumParameters, fn.NumLocals)
+		pending = append(pending, d.function(fn.Instructions, fn.Lines)...)
+	}
+
+	return out.String()
+}
+
+type disassembler struct {
+	out       *bytes.Buffer
+	constants []object.Object
+	seen      map[int]bool // function constants already queued for listing
+}
+
+// function : print one function's instructions and return the indexes of
+// newly referenced function constants
+func (d *disassembler) function(ins code.Instructions, lines []code.SourceLine) []int {
+	var referenced []int
+	lastLine := -1
+
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			fmt.Fprintf(d.out, "%04d ERROR: %s\n", ip, err)
+			ip++
+			continue
+		}
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+
+		line := code.LineAt(lines, ip)
+		if line != lastLine {
+			fmt.Fprintf(d.out, "%04d %4d ", ip, line)
+			lastLine = line
+		} else {
+			fmt.Fprintf(d.out, "%04d    | ", ip)
+		}
+
+		text := def.Name
+		for _, o := range operands {
+			text += fmt.Sprintf(" %d", o)
+		}
+
+		switch code.Opcode(ins[ip]) {
+		case code.OpConstant:
+			fmt.Fprintf(d.out, "%-24s ; %s\n", text, d.constant(operands[0]))
+		case code.OpClosure:
+			fmt.Fprintf(d.out, "%-24s ; %s\n", text, d.constant(operands[0]))
+			if _, ok := d.constants[operands[0]].(*object.CompiledFunction); ok && !d.seen[operands[0]] {
+				d.seen[operands[0]] = true
+				referenced = append(referenced, operands[0])
+			}
+		default:
+			fmt.Fprintf(d.out, "%s\n", text)
+		}
+
+		ip += 1 + read
+	}
+
+	return referenced
+}
+
+func (d *disassembler) constant(index int) string {
+	if index >= len(d.constants) {
+		return "<invalid constant>"
+	}
+
+	switch constant := d.constants[index].(type) {
+	case *object.CompiledFunction:
+		return fmt.Sprintf("<fn constant %d>", index)
+	default:
+		return constant.Inspect()
+	}
+}
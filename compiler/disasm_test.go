@@ -0,0 +1,47 @@
+package compiler
+
+import "testing"
+
+func TestDisassemble(t *testing.T) {
+	input := `let a = 5;
+let newAdder = fn(x) {
+	fn(y) { x + y + a }
+};
+newAdder(2)(3);`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+
+	expected := `== main ==
+0000    1 OpConstant 0             ; 5
+0003    | OpSetGlobal 0
+0006    2 OpClosure 2 0            ; <fn constant 2>
+0010    | OpSetGlobal 1
+0013    5 OpGetGlobal 1
+0016    | OpConstant 3             ; 2
+0019    | OpCall 1
+0021    | OpConstant 4             ; 3
+0024    | OpCall 1
+0026    | OpPop
+
+== constant 2: fn(parameters=1, locals=1) ==
+0000    3 OpGetLocal 0
+0002    | OpClosure 1 1            ; <fn constant 1>
+0006    | OpReturnValue
+
+== constant 1: fn(parameters=1, locals=1) ==
+0000    3 OpGetFree 0
+0002    | OpGetLocal 0
+0004    | OpAdd
+0005    | OpGetGlobal 0
+0008    | OpAdd
+0009    | OpReturnValue
+`
+
+	actual := Disassemble(compiler.Bytecode())
+	if actual != expected {
+		t.Errorf("wrong disassembly.\nwant:\n%s\ngot:\n%s", expected, actual)
+	}
+}
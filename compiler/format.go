@@ -0,0 +1,448 @@
+package compiler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/rockspore/monkey-interpreter/code"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+// Layout of a compiled Monkey file (.mkc), all integers being varints:
+//
+//	magic "MKC\x00" | version | main function | constant count | constants...
+//
+// A function is encoded as
+//
+//	numLocals | numParameters | instruction length | instructions |
+//	line count | (offset, line)...
+//
+// and every constant is a tag byte followed by either a signed integer, a
+// length-prefixed string or a function. Nested functions are constants of their own, referenced by index
+// from OpClosure instructions.
+
+// Magic : the first bytes of every compiled Monkey file
+const Magic = "MKC\x00"
+
+// FormatVersion : the version of the binary format written by WriteBytecode
+const FormatVersion = 1
+
+// maxLocals : the number of locals addressable by OpGetLocal and OpSetLocal
+const maxLocals = 256
+
+const (
+	constantInteger  byte = 'I'
+	constantString   byte = 'S'
+	constantFunction byte = 'F'
+)
+
+// WriteBytecode : serialize bytecode into w
+func WriteBytecode(w io.Writer, bytecode *Bytecode) error {
+	buf := []byte(Magic)
+	buf = binary.AppendUvarint(buf, FormatVersion)
+
+	buf = appendFunction(buf, &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Lines:        bytecode.Lines,
+	})
+
+	buf = binary.AppendUvarint(buf, uint64(len(bytecode.Constants)))
+	for i, constant := range bytecode.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			buf = append(buf, constantInteger)
+			buf = binary.AppendVarint(buf, constant.Value)
+		case *object.String:
+			buf = append(buf, constantString)
+			buf = binary.AppendUvarint(buf, uint64(len(constant.Value)))
+			buf = append(buf, constant.Value...)
+		case *object.CompiledFunction:
+			buf = append(buf, constantFunction)
+			buf = appendFunction(buf, constant)
+		default:
+			return fmt.Errorf("constant %d: cannot serialize %s", i, constant.Type())
+		}
+	}
+
+	_, err := w.Write(buf)
+	return err
+}
+
+func appendFunction(buf []byte, fn *object.CompiledFunction) []byte {
+	buf = binary.AppendUvarint(buf, uint64(fn.NumLocals))
+	buf = binary.AppendUvarint(buf, uint64(fn.NumParameters))
+	buf = binary.AppendUvarint(buf, uint64(len(fn.Instructions)))
+	buf = append(buf, fn.Instructions...)
+
+	buf = binary.AppendUvarint(buf, uint64(len(fn.Lines)))
+	for _, l := range fn.Lines {
+		buf = binary.AppendUvarint(buf, uint64(l.Offset))
+		buf = binary.AppendUvarint(buf, uint64(l.Line))
+	}
+	return buf
+}
+
+// ReadBytecode : deserialize and validate bytecode written by WriteBytecode
+func ReadBytecode(r io.Reader) (*Bytecode, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(data, []byte(Magic)) {
+		return nil, errors.New("not a compiled Monkey file: bad magic")
+	}
+
+	d := &decoder{r: bytes.NewReader(data[len(Magic):])}
+
+	version := d.uvarint()
+	if d.err == nil && version != FormatVersion {
+		return nil, fmt.Errorf("unsupported format version %d, want %d",
+			version, FormatVersion)
+	}
+
+	main := d.function()
+
+	numConstants := d.length()
+	constants := make([]object.Object, 0, numConstants)
+	for i := 0; i < numConstants && d.err == nil; i++ {
+		switch tag := d.byte(); tag {
+		case constantInteger:
+			constants = append(constants, &object.Integer{Value: d.varint()})
+		case constantString:
+			constants = append(constants, &object.String{Value: d.string()})
+		case constantFunction:
+			constants = append(constants, d.function())
+		default:
+			d.fail(fmt.Errorf("constant %d: unknown tag %q", i, tag))
+		}
+	}
+
+	if d.err == nil && d.r.Len() != 0 {
+		d.fail(fmt.Errorf("%d bytes of trailing data", d.r.Len()))
+	}
+	if d.err != nil {
+		return nil, fmt.Errorf("malformed compiled Monkey file: %s", d.err)
+	}
+
+	bytecode := &Bytecode{
+		Instructions: main.Instructions,
+		Lines:        main.Lines,
+		Constants:    constants,
+	}
+	if err := validate(bytecode); err != nil {
+		return nil, fmt.Errorf("invalid compiled Monkey file: %s", err)
+	}
+
+	return bytecode, nil
+}
+
+// decoder : reads varint-encoded values, remembering the first error
+type decoder struct {
+	r   *bytes.Reader
+	err error
+}
+
+func (d *decoder) fail(err error) {
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *decoder) byte() byte {
+	if d.err != nil {
+		return 0
+	}
+	b, err := d.r.ReadByte()
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return b
+}
+
+func (d *decoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return v
+}
+
+func (d *decoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, err := binary.ReadVarint(d.r)
+	if err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return v
+}
+
+// length : read a count of items, each taking at least one byte of input
+func (d *decoder) length() int {
+	n := d.uvarint()
+	if n > uint64(d.r.Len()) {
+		d.fail(io.ErrUnexpectedEOF)
+		return 0
+	}
+	return int(n)
+}
+
+func (d *decoder) string() string {
+	buf := make([]byte, d.length())
+	if _, err := io.ReadFull(d.r, buf); err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+	return string(buf)
+}
+
+func (d *decoder) function() *object.CompiledFunction {
+	fn := &object.CompiledFunction{}
+	fn.NumLocals = int(d.uvarint())
+	fn.NumParameters = int(d.uvarint())
+	if fn.NumLocals > maxLocals || fn.NumParameters > fn.NumLocals {
+		d.fail(fmt.Errorf("bad function frame: %d locals, %d parameters",
+			fn.NumLocals, fn.NumParameters))
+	}
+
+	fn.Instructions = make(code.Instructions, d.length())
+	if _, err := io.ReadFull(d.r, fn.Instructions); err != nil {
+		d.fail(io.ErrUnexpectedEOF)
+	}
+
+	numLines := d.length()
+	for i := 0; i < numLines && d.err == nil; i++ {
+		offset, line := d.length(), d.uvarint()
+		fn.Lines = append(fn.Lines, code.SourceLine{Offset: offset, Line: int(line)})
+	}
+
+	return fn
+}
+
+// validate : check that every instruction is well-formed and refers to
+// existing constants, locals, free variables and jump targets, and that no
+// path through a function pops more values than it pushed, so that the vm
+// cannot be crashed by a corrupt file
+func validate(bytecode *Bytecode) error {
+	if err := validateInstructions(bytecode.Instructions, 0, bytecode.Constants); err != nil {
+		return fmt.Errorf("main: %s", err)
+	}
+
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if err := validateInstructions(fn.Instructions, fn.NumLocals, bytecode.Constants); err != nil {
+			return fmt.Errorf("constant %d: %s", i, err)
+		}
+	}
+
+	numFree, err := closureSizes(bytecode)
+	if err != nil {
+		return err
+	}
+
+	if err := validateStack(bytecode.Instructions, 0, true); err != nil {
+		return fmt.Errorf("main: %s", err)
+	}
+	for i, constant := range bytecode.Constants {
+		fn, ok := constant.(*object.CompiledFunction)
+		if !ok {
+			continue
+		}
+		if err := validateStack(fn.Instructions, numFree[i], false); err != nil {
+			return fmt.Errorf("constant %d: %s", i, err)
+		}
+	}
+
+	return nil
+}
+
+// closureSizes : return the number of free variables each function constant
+// is closed over with, which all OpClosure instructions must agree on
+func closureSizes(bytecode *Bytecode) (map[int]int, error) {
+	numFree := map[int]int{}
+
+	sequences := []code.Instructions{bytecode.Instructions}
+	for _, constant := range bytecode.Constants {
+		if fn, ok := constant.(*object.CompiledFunction); ok {
+			sequences = append(sequences, fn.Instructions)
+		}
+	}
+
+	for _, ins := range sequences {
+		for ip := 0; ip < len(ins); {
+			def, _ := code.Lookup(ins[ip])
+			operands, read := code.ReadOperands(def, ins[ip+1:])
+			if code.Opcode(ins[ip]) == code.OpClosure {
+				if n, ok := numFree[operands[0]]; ok && n != operands[1] {
+					return nil, fmt.Errorf("constant %d: closed over %d and %d free variables",
+						operands[0], n, operands[1])
+				}
+				numFree[operands[0]] = operands[1]
+			}
+			ip += 1 + read
+		}
+	}
+
+	return numFree, nil
+}
+
+// validateStack : follow every path through well-formed instructions,
+// checking that each instruction finds the values it pops on the stack, that
+// paths meeting at an instruction agree on the stack depth, that jumps land
+// on instructions, and that no path runs off the end of a function
+func validateStack(ins code.Instructions, numFree int, main bool) error {
+	starts := map[int]bool{len(ins): true}
+	for ip := 0; ip < len(ins); {
+		starts[ip] = true
+		def, _ := code.Lookup(ins[ip])
+		_, read := code.ReadOperands(def, ins[ip+1:])
+		ip += 1 + read
+	}
+
+	depths := map[int]int{0: 0}
+	pending := []int{0}
+	for len(pending) > 0 {
+		ip := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if ip == len(ins) {
+			if !main {
+				return fmt.Errorf("offset %d: function ends without a return", ip)
+			}
+			continue
+		}
+
+		op := code.Opcode(ins[ip])
+		def, _ := code.Lookup(ins[ip])
+		operands, read := code.ReadOperands(def, ins[ip+1:])
+
+		depth := depths[ip]
+		pops, pushes := stackEffect(op, operands)
+		if depth < pops {
+			return fmt.Errorf("offset %d: stack underflow in %s", ip, def.Name)
+		}
+
+		next := []int{ip + 1 + read}
+		switch op {
+		case code.OpGetFree:
+			if operands[0] >= numFree {
+				return fmt.Errorf("offset %d: free variable %d out of range", ip, operands[0])
+			}
+		case code.OpJump:
+			next = []int{operands[0]}
+		case code.OpJumpNotTruthy:
+			next = append(next, operands[0])
+		case code.OpReturnValue:
+			next = nil
+		case code.OpReturn:
+			if main {
+				return fmt.Errorf("offset %d: OpReturn outside a function", ip)
+			}
+			next = nil
+		}
+
+		for _, target := range next {
+			if !starts[target] {
+				return fmt.Errorf("offset %d: jump target %d is not an instruction", ip, target)
+			}
+			want := depth - pops + pushes
+			if got, ok := depths[target]; ok {
+				if got != want {
+					return fmt.Errorf("offset %d: stack depth %d, but %d on another path", target, want, got)
+				}
+				continue
+			}
+			depths[target] = want
+			pending = append(pending, target)
+		}
+	}
+
+	return nil
+}
+
+// stackEffect : return how many values an instruction pops and pushes
+func stackEffect(op code.Opcode, operands []int) (int, int) {
+	switch op {
+	case code.OpPop, code.OpJumpNotTruthy, code.OpSetGlobal, code.OpSetLocal, code.OpReturnValue:
+		return 1, 0
+	case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+		code.OpEqual, code.OpNotEqual,
+		code.OpGreaterThan, code.OpGreaterEqual,
+		code.OpLessThan, code.OpLessEqual,
+		code.OpSetAttr:
+		return 2, 1
+	case code.OpMinus, code.OpBang, code.OpGetAttr:
+		return 1, 1
+	case code.OpClosure:
+		return operands[1], 1
+	case code.OpCall:
+		return operands[0] + 1, 1
+	case code.OpCallMethod:
+		return operands[1] + 1, 1
+	case code.OpJump, code.OpReturn:
+		return 0, 0
+	default:
+		// constants, globals, locals, free variables and the closure itself
+		return 0, 1
+	}
+}
+
+func validateInstructions(ins code.Instructions, numLocals int, constants []object.Object) error {
+	for ip := 0; ip < len(ins); {
+		def, err := code.Lookup(ins[ip])
+		if err != nil {
+			return fmt.Errorf("offset %d: %s", ip, err)
+		}
+
+		width := 0
+		for _, w := range def.OperandWidths {
+			width += w
+		}
+		if ip+1+width > len(ins) {
+			return fmt.Errorf("offset %d: truncated %s", ip, def.Name)
+		}
+
+		operands, _ := code.ReadOperands(def, ins[ip+1:])
+		switch code.Opcode(ins[ip]) {
+		case code.OpConstant:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("offset %d: constant %d out of range", ip, operands[0])
+			}
+		case code.OpClosure:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("offset %d: constant %d out of range", ip, operands[0])
+			}
+			if _, ok := constants[operands[0]].(*object.CompiledFunction); !ok {
+				return fmt.Errorf("offset %d: constant %d is not a function", ip, operands[0])
+			}
+		case code.OpGetAttr, code.OpSetAttr, code.OpCallMethod:
+			if operands[0] >= len(constants) {
+				return fmt.Errorf("offset %d: constant %d out of range", ip, operands[0])
+			}
+			if _, ok := constants[operands[0]].(*object.String); !ok {
+				return fmt.Errorf("offset %d: constant %d is not a name", ip, operands[0])
+			}
+		case code.OpGetLocal, code.OpSetLocal:
+			if operands[0] >= numLocals {
+				return fmt.Errorf("offset %d: local %d out of range", ip, operands[0])
+			}
+		case code.OpJump, code.OpJumpNotTruthy:
+			if operands[0] > len(ins) {
+				return fmt.Errorf("offset %d: jump target %d out of range", ip, operands[0])
+			}
+		}
+
+		ip += 1 + width
+	}
+
+	return nil
+}
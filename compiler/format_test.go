@@ -0,0 +1,187 @@
+package compiler
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/code"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+func TestBytecodeRoundTrip(t *testing.T) {
+	input := `let a = -5;
+let newAdder = fn(x) {
+	fn(y) { x + y + a };
+};
+newAdder(2)(3);
+a.b = a.c(a.b);`
+
+	compiler := New()
+	if err := compiler.Compile(parse(input)); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	expected := compiler.Bytecode()
+
+	var buf bytes.Buffer
+	if err := WriteBytecode(&buf, expected); err != nil {
+		t.Fatalf("WriteBytecode failed: %s", err)
+	}
+
+	actual, err := ReadBytecode(&buf)
+	if err != nil {
+		t.Fatalf("ReadBytecode failed: %s", err)
+	}
+
+	if err := testInstructions([]code.Instructions{expected.Instructions}, actual.Instructions); err != nil {
+		t.Errorf("main: %s", err)
+	}
+	if len(actual.Lines) != len(expected.Lines) {
+		t.Errorf("wrong main line table. want=%v, got=%v", expected.Lines, actual.Lines)
+	}
+
+	if len(actual.Constants) != len(expected.Constants) {
+		t.Fatalf("wrong number of constants. want=%d, got=%d",
+			len(expected.Constants), len(actual.Constants))
+	}
+	for i, constant := range expected.Constants {
+		switch constant := constant.(type) {
+		case *object.Integer:
+			if err := testConstants([]interface{}{int(constant.Value)}, actual.Constants[i:i+1]); err != nil {
+				t.Errorf("%s", err)
+			}
+		case *object.String:
+			s, ok := actual.Constants[i].(*object.String)
+			if !ok || s.Value != constant.Value {
+				t.Errorf("constant %d - wrong string. want=%q, got=%+v", i, constant.Value, actual.Constants[i])
+			}
+		case *object.CompiledFunction:
+			fn, ok := actual.Constants[i].(*object.CompiledFunction)
+			if !ok {
+				t.Fatalf("constant %d - not a function: %T", i, actual.Constants[i])
+			}
+			if err := testInstructions([]code.Instructions{constant.Instructions}, fn.Instructions); err != nil {
+				t.Errorf("constant %d - %s", i, err)
+			}
+			if fn.NumLocals != constant.NumLocals || fn.NumParameters != constant.NumParameters {
+				t.Errorf("constant %d - wrong frame. want=%+v, got=%+v", i, constant, fn)
+			}
+			if len(fn.Lines) != len(constant.Lines) || fn.Lines[0] != constant.Lines[0] {
+				t.Errorf("constant %d - wrong line table. want=%v, got=%v",
+					i, constant.Lines, fn.Lines)
+			}
+		}
+	}
+}
+
+func TestReadBytecodeErrors(t *testing.T) {
+	compiler := New()
+	if err := compiler.Compile(parse("let f = fn(x) { x * 2 }; f(21);")); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	var buf bytes.Buffer
+	if err := WriteBytecode(&buf, compiler.Bytecode()); err != nil {
+		t.Fatalf("WriteBytecode failed: %s", err)
+	}
+	valid := buf.Bytes()
+
+	// main: OpClosure 1 0, OpSetGlobal 0, ... with the closure's constant
+	// index at bytes 1-2 of the instructions
+	mainStart := len(Magic) + 1 + 1 + 1 + 1
+	badConstant := append([]byte{}, valid...)
+	badConstant[mainStart+2] = 9
+
+	badOpcode := append([]byte{}, valid...)
+	badOpcode[mainStart] = 255
+
+	tests := []struct {
+		data     []byte
+		expected string
+	}{
+		{[]byte("MKX\x00"), "bad magic"},
+		{append([]byte(Magic), 9), "unsupported format version 9"},
+		{valid[:len(valid)-3], "unexpected EOF"},
+		{append(append([]byte{}, valid...), 0), "trailing data"},
+		{badConstant, "constant 9 out of range"},
+		{badOpcode, "opcode 255 undefined"},
+	}
+
+	for _, tt := range tests {
+		_, err := ReadBytecode(bytes.NewReader(tt.data))
+		if err == nil {
+			t.Errorf("expected error containing %q, got none", tt.expected)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.expected) {
+			t.Errorf("wrong error. want substring %q, got=%q", tt.expected, err)
+		}
+	}
+}
+
+func TestValidateStack(t *testing.T) {
+	concat := func(ins ...[]byte) code.Instructions {
+		out := code.Instructions{}
+		for _, in := range ins {
+			out = append(out, in...)
+		}
+		return out
+	}
+	function := func(ins ...[]byte) *object.CompiledFunction {
+		return &object.CompiledFunction{Instructions: concat(ins...)}
+	}
+
+	tests := []struct {
+		main      code.Instructions
+		constants []object.Object
+		expected  string
+	}{
+		{concat(code.Make(code.OpPop)), nil, "main: offset 0: stack underflow in OpPop"},
+		{concat(code.Make(code.OpTrue), code.Make(code.OpAdd)), nil, "main: offset 1: stack underflow in OpAdd"},
+		{concat(code.Make(code.OpReturn)), nil, "main: offset 0: OpReturn outside a function"},
+		{concat(code.Make(code.OpClosure, 0, 2)), []object.Object{function(code.Make(code.OpReturn))},
+			"main: offset 0: stack underflow in OpClosure"},
+		{concat(code.Make(code.OpClosure, 0, 0)), []object.Object{function(code.Make(code.OpGetFree, 0), code.Make(code.OpReturnValue))},
+			"constant 0: offset 0: free variable 0 out of range"},
+		{concat(code.Make(code.OpTrue), code.Make(code.OpClosure, 0, 0), code.Make(code.OpClosure, 0, 1)),
+			[]object.Object{function(code.Make(code.OpReturn))},
+			"constant 0: closed over 0 and 1 free variables"},
+		{concat(code.Make(code.OpJump, 1)), nil, "main: offset 0: jump target 1 is not an instruction"},
+		{concat(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 5), code.Make(code.OpTrue), code.Make(code.OpNull)), nil,
+			"main: offset 5: stack depth 1, but 0 on another path"},
+		{concat(code.Make(code.OpConstant, 0), code.Make(code.OpPop)), []object.Object{function(code.Make(code.OpPop))},
+			"constant 0: offset 0: stack underflow in OpPop"},
+		{concat(code.Make(code.OpConstant, 0), code.Make(code.OpPop)), []object.Object{function(code.Make(code.OpTrue), code.Make(code.OpPop))},
+			"constant 0: offset 2: function ends without a return"},
+		{concat(code.Make(code.OpConstant, 0), code.Make(code.OpPop)),
+			[]object.Object{function(code.Make(code.OpTrue), code.Make(code.OpJumpNotTruthy, 5), code.Make(code.OpReturn))},
+			"constant 0: offset 5: function ends without a return"},
+	}
+
+	for _, tt := range tests {
+		err := validate(&Bytecode{Instructions: tt.main, Constants: tt.constants})
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%v", tt.expected, err)
+		}
+	}
+}
+
+func TestValidateCompiledPrograms(t *testing.T) {
+	inputs := []string{
+		"if (true) { 1 }; if (false) { 1 } else { 2 }; 3",
+		"let i = 0; while (i < 3) { let i = i + 1; }; i",
+		"let f = fn(x) { if (x) { return 1; }; let y = 2; while (x) { return y; }; y }; f(true)",
+		"let f = fn(a) { let g = fn(b) { fn() { a + b } }; g(2)() }; f(1)",
+		"let f = fn() { g() }; let g = fn() { 1 }; return f();",
+		"let a = 1; let f = fn() { }; f(); a.b = a.c(1, 2).d;",
+	}
+
+	for _, input := range inputs {
+		compiler := New()
+		if err := compiler.Compile(parse(input)); err != nil {
+			t.Fatalf("%s: compiler error: %s", input, err)
+		}
+		if err := validate(compiler.Bytecode()); err != nil {
+			t.Errorf("%s: compiled bytecode rejected: %s", input, err)
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package compiler
+
+import "sort"
+
+// SymbolScope : where the value bound to a symbol lives at runtime
+type SymbolScope string
+
+// Scopes of symbols
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+// Symbol : a resolved identifier
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// SymbolTable : the symbols defined in one scope
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	store          map[string]Symbol
+	numDefinitions int
+
+	FreeSymbols []Symbol
+}
+
+// NewSymbolTable : create a new global symbol table
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	return &SymbolTable{store: s}
+}
+
+// NewEnclosedSymbolTable : create a new local symbol table inside outer
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Names : return the sorted names defined in this scope
+func (s *SymbolTable) Names() []string {
+	names := []string{}
+	for name := range s.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Clone : return a copy of a global symbol table, so that definitions made
+// while compiling a program can be dropped when it fails
+func (s *SymbolTable) Clone() *SymbolTable {
+	clone := NewSymbolTable()
+	clone.Outer = s.Outer
+	for name, symbol := range s.store {
+		clone.store[name] = symbol
+	}
+	clone.numDefinitions = s.numDefinitions
+	return clone
+}
+
+// GlobalNames : return the names of the global symbols indexed by slot
+func (s *SymbolTable) GlobalNames() []string {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		if symbol.Scope == GlobalScope {
+			names[symbol.Index] = name
+		}
+	}
+	return names
+}
+
+// LocalNames : return the names of the local symbols of this table indexed
+// by slot
+func (s *SymbolTable) LocalNames() []string {
+	names := make([]string, s.numDefinitions)
+	for name, symbol := range s.store {
+		if symbol.Scope == LocalScope {
+			names[symbol.Index] = name
+		}
+	}
+	return names
+}
+
+// Define : bind name to a new slot, or return its existing slot when the name
+// is already defined in this scope, so that `let x = x + 1` rebinds in place
+func (s *SymbolTable) Define(name string) Symbol {
+	if symbol, ok := s.store[name]; ok {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			return symbol
+		}
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions}
+	if s.Outer == nil {
+		symbol.Scope = GlobalScope
+	} else {
+		symbol.Scope = LocalScope
+	}
+
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+// DefineGlobal : bind name in the outermost table, whatever table it is
+// called on
+func (s *SymbolTable) DefineGlobal(name string) Symbol {
+	for s.Outer != nil {
+		s = s.Outer
+	}
+	return s.Define(name)
+}
+
+// DefineFunctionName : bind the name of the function being compiled so it can
+// refer to itself
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// Resolve : look up name in this scope and its enclosing ones, turning
+// locals of enclosing functions into free symbols
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	symbol, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		symbol, ok = s.Outer.Resolve(name)
+		if !ok {
+			return symbol, ok
+		}
+
+		if symbol.Scope == GlobalScope {
+			return symbol, ok
+		}
+
+		free := s.defineFree(symbol)
+		return free, true
+	}
+
+	return symbol, ok
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1}
+	symbol.Scope = FreeScope
+
+	s.store[original.Name] = symbol
+	return symbol
+}
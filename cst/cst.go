@@ -0,0 +1,87 @@
+package cst
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/parser"
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+// Tree : a parsed program together with all of its tokens and the whitespace
+// and comments between them, so that it prints back to its exact source
+type Tree struct {
+	Program *ast.Program
+	Tokens  []token.Token
+
+	parser *parser.Parser
+}
+
+// Parse : parse src into a lossless tree
+func Parse(src string) (*Tree, error) {
+	p := parser.New(lexer.NewWithTrivia(src), parser.WithSpans())
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("parse errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+
+	t := &Tree{Program: program, Tokens: p.Tokens(), parser: p}
+	if t.String() != src {
+		// the lexer stops at a NUL byte
+		return nil, fmt.Errorf("source cannot be represented losslessly")
+	}
+	return t, nil
+}
+
+// String : return the source the tree was parsed from, byte for byte
+func (t *Tree) String() string {
+	var out strings.Builder
+	writeTokens(&out, t.Tokens)
+	return out.String()
+}
+
+// Text : return the source of a node in the tree, without the whitespace and
+// comments before its first token
+func (t *Tree) Text(node ast.Node) (string, bool) {
+	span, ok := t.parser.Span(node)
+	if !ok {
+		return "", false
+	}
+
+	var out strings.Builder
+	out.WriteString(t.Tokens[span.First].Literal)
+	writeTokens(&out, t.Tokens[span.First+1:span.Last+1])
+	return out.String(), true
+}
+
+// Replace : return a new tree with the source of node replaced by text. All
+// other source, including the whitespace and comments before the node, is
+// kept as it was. The edited source must still parse.
+func (t *Tree) Replace(node ast.Node, text string) (*Tree, error) {
+	span, ok := t.parser.Span(node)
+	if !ok {
+		return nil, fmt.Errorf("node is not part of the tree: %s", node.String())
+	}
+
+	var out strings.Builder
+	writeTokens(&out, t.Tokens[:span.First])
+	for _, trivia := range t.Tokens[span.First].Leading {
+		out.WriteString(trivia.Text)
+	}
+	out.WriteString(text)
+	writeTokens(&out, t.Tokens[span.Last+1:])
+
+	return Parse(out.String())
+}
+
+func writeTokens(out *strings.Builder, tokens []token.Token) {
+	for _, tok := range tokens {
+		for _, trivia := range tok.Leading {
+			out.WriteString(trivia.Text)
+		}
+		out.WriteString(tok.Literal)
+	}
+}
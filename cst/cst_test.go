@@ -0,0 +1,121 @@
+package cst
+
+import (
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+)
+
+func TestRoundTrip(t *testing.T) {
+	inputs := []string{
+		"",
+		"   \n\t\r\n",
+		"let x = 5;",
+		"let   add=fn( a ,b ){\n\ta+b // sum\n} ;\n\n\nadd( 1,2 )",
+		"// only a comment",
+		"if((1 + 2) * 3 >= 9){ true }else{ false }   // trailing\n",
+		"while (i < 3) {\r\n  let i = i + 1;\r\n}\r\n",
+		"x // comment without newline",
+	}
+
+	for _, input := range inputs {
+		tree, err := Parse(input)
+		if err != nil {
+			t.Errorf("Parse(%q) returned error: %s", input, err)
+			continue
+		}
+		if tree.String() != input {
+			t.Errorf("round trip wrong. expected=%q, got=%q", input, tree.String())
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{"let = 5;", "a\x00b"} {
+		if _, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) expected an error", input)
+		}
+	}
+}
+
+func TestText(t *testing.T) {
+	input := "let a = ( 1 +  2 ) * 3; // c\nlet f = fn(x) {\n  x\n};"
+
+	tree, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse returned error: %s", err)
+	}
+
+	first := tree.Program.Statements[0].(*ast.LetStatement)
+	product := first.Value.(*ast.InfixExpression)
+	second := tree.Program.Statements[1].(*ast.LetStatement)
+	function := second.Value.(*ast.FunctionLiteral)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{first, "let a = ( 1 +  2 ) * 3;"},
+		{first.Name, "a"},
+		{product, "( 1 +  2 ) * 3"},
+		{product.Left, "( 1 +  2 )"},
+		{product.Right, "3"},
+		{second, "let f = fn(x) {\n  x\n};"},
+		{function.Parameters[0], "x"},
+		{function.Body, "{\n  x\n}"},
+		{function.Body.Statements[0], "x"},
+		{tree.Program, input},
+	}
+
+	for _, tt := range tests {
+		text, ok := tree.Text(tt.node)
+		if !ok {
+			t.Errorf("no text for %s", tt.node.String())
+			continue
+		}
+		if text != tt.expected {
+			t.Errorf("text of %s wrong. expected=%q, got=%q", tt.node.String(), tt.expected, text)
+		}
+	}
+
+	if _, ok := tree.Text(&ast.Identifier{Value: "z"}); ok {
+		t.Errorf("expected no text for a node outside the tree")
+	}
+}
+
+func TestReplace(t *testing.T) {
+	input := "// setup\nlet a = 1 +  2; // keep me\n\nlet b = a;\n"
+
+	tree, err := Parse(input)
+	if err != nil {
+		t.Fatalf("Parse returned error: %s", err)
+	}
+
+	value := tree.Program.Statements[0].(*ast.LetStatement).Value
+	edited, err := tree.Replace(value, "fn(x) { x }(3)")
+	if err != nil {
+		t.Fatalf("Replace returned error: %s", err)
+	}
+
+	expected := "// setup\nlet a = fn(x) { x }(3); // keep me\n\nlet b = a;\n"
+	if edited.String() != expected {
+		t.Errorf("edited source wrong. expected=%q, got=%q", expected, edited.String())
+	}
+	if _, ok := edited.Program.Statements[0].(*ast.LetStatement).Value.(*ast.CallExpression); !ok {
+		t.Errorf("edited tree not reparsed. got=%s", edited.Program.String())
+	}
+
+	second := tree.Program.Statements[1]
+	edited, err = tree.Replace(second, "")
+	if err != nil {
+		t.Fatalf("Replace returned error: %s", err)
+	}
+	expected = "// setup\nlet a = 1 +  2; // keep me\n\n\n"
+	if edited.String() != expected {
+		t.Errorf("edited source wrong. expected=%q, got=%q", expected, edited.String())
+	}
+
+	if _, err := tree.Replace(value, "1 +"); err == nil {
+		t.Errorf("expected an error for an edit that does not parse")
+	}
+}
@@ -0,0 +1,510 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrInterrupted : returned by ReadLine when the line is abandoned with Ctrl-C
+var ErrInterrupted = errors.New("interrupted")
+
+// MaxHistory : the number of lines kept in the history
+const MaxHistory = 1000
+
+// Editor : reads lines with cursor movement, history, reverse history search
+// and tab completion
+type Editor struct {
+	in  *bufio.Reader
+	out io.Writer
+	fd  int // terminal switched to raw mode while reading a line, or -1
+
+	history     []string
+	historyFile string
+
+	// Complete : return the candidates for the word before the cursor; a
+	// word is a run of letters, digits, underscores and colons
+	Complete func(word string) []string
+
+	// Highlight : decorate the line for display with escape sequences that do
+	// not move the cursor
+	Highlight func(line string) string
+}
+
+// Available : report whether in is a terminal the editor can drive
+func Available(in io.Reader) bool {
+	f, ok := in.(*os.File)
+	return ok && isTerminal(int(f.Fd()))
+}
+
+// New : create an editor reading keys from in and drawing on out. When in is
+// a terminal it is switched to raw mode while each line is read.
+func New(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{in: bufio.NewReader(in), out: out, fd: -1}
+	if f, ok := in.(*os.File); ok && isTerminal(int(f.Fd())) {
+		e.fd = int(f.Fd())
+	}
+	return e
+}
+
+// LoadHistory : read the history from a file, one entry per line, and
+// append every later entry to it. A missing file is not an error.
+func (e *Editor) LoadHistory(path string) error {
+	e.historyFile = path
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line != "" {
+			e.history = append(e.history, line)
+		}
+	}
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+		// compact the file to the kept entries
+		return os.WriteFile(path, []byte(strings.Join(e.history, "\n")+"\n"), 0600)
+	}
+	return nil
+}
+
+// History : return the history entries, oldest first
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// AddHistory : append a line to the history, and to the history file if one
+// was loaded. Blank lines and repeats of the last entry are skipped.
+func (e *Editor) AddHistory(line string) error {
+	if strings.TrimSpace(line) == "" || strings.Contains(line, "\n") {
+		return nil
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return nil
+	}
+
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[1:]
+	}
+
+	if e.historyFile == "" {
+		return nil
+	}
+	f, err := os.OpenFile(e.historyFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(line + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadLine : read one line, showing prompt before it. Returns io.EOF on
+// Ctrl-D in an empty line and ErrInterrupted on Ctrl-C.
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.fd >= 0 {
+		restore, err := makeRaw(e.fd)
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	l := &line{e: e, prompt: prompt, historyIndex: len(e.history)}
+	l.refresh()
+
+	for {
+		r, _, err := e.in.ReadRune()
+		if err == io.EOF && len(l.buf) > 0 {
+			io.WriteString(e.out, "\r\n")
+			return string(l.buf), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		done, handled := false, false
+		if l.search != nil {
+			done, handled = l.handleSearch(r)
+		}
+		if !handled {
+			done, err = l.handle(r)
+		}
+		if err != nil {
+			io.WriteString(e.out, "\r\n")
+			return "", err
+		}
+		if done {
+			l.search = nil
+			l.pos = len(l.buf)
+			l.refresh()
+			io.WriteString(e.out, "\r\n")
+			return string(l.buf), nil
+		}
+		l.refresh()
+	}
+}
+
+// line : the state of the line being edited
+type line struct {
+	e      *Editor
+	prompt string
+	buf    []rune
+	pos    int
+
+	historyIndex int
+	saved        []rune // the edited line while browsing the history
+
+	search *search // set during a Ctrl-R search
+}
+
+// search : the state of a reverse incremental history search
+type search struct {
+	query  []rune
+	index  int // history entry matched, or -1
+	failed bool
+	saved  []rune
+}
+
+// Control keys
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	escape    = 27
+	backspace = 127
+)
+
+// handle : apply a key to the line, reporting whether the line is done
+func (l *line) handle(r rune) (bool, error) {
+	switch r {
+	case '\r', '\n':
+		return true, nil
+	case ctrlC:
+		return false, ErrInterrupted
+	case ctrlD:
+		if len(l.buf) == 0 {
+			return false, io.EOF
+		}
+		l.delete()
+	case ctrlA:
+		l.pos = 0
+	case ctrlE:
+		l.pos = len(l.buf)
+	case ctrlB:
+		l.left()
+	case ctrlF:
+		l.right()
+	case ctrlH, backspace:
+		if l.pos > 0 {
+			l.pos--
+			l.delete()
+		}
+	case ctrlK:
+		l.buf = l.buf[:l.pos]
+	case ctrlU:
+		l.buf = append([]rune{}, l.buf[l.pos:]...)
+		l.pos = 0
+	case ctrlW:
+		// the spaces before the cursor, then the word before them
+		start := l.pos
+		for start > 0 && unicode.IsSpace(l.buf[start-1]) {
+			start--
+		}
+		for start > 0 && !unicode.IsSpace(l.buf[start-1]) {
+			start--
+		}
+		l.buf = append(l.buf[:start], l.buf[l.pos:]...)
+		l.pos = start
+	case ctrlP:
+		l.previous()
+	case ctrlN:
+		l.next()
+	case ctrlR:
+		l.search = &search{index: len(l.e.history), saved: append([]rune{}, l.buf...)}
+		l.searchFrom(len(l.e.history) - 1)
+	case tab:
+		l.complete()
+	case escape:
+		l.escape()
+	default:
+		if unicode.IsPrint(r) {
+			l.insert(r)
+		}
+	}
+	return false, nil
+}
+
+// escapeSequence : report whether the rest of an escape sequence follows an
+// ESC, which the terminal sends along with it
+func (l *line) escapeSequence() bool {
+	if l.e.in.Buffered() == 0 {
+		return false
+	}
+	next, err := l.e.in.Peek(1)
+	return err == nil && (next[0] == '[' || next[0] == 'O')
+}
+
+// escape : handle the rest of an escape sequence for arrows, home, end and
+// delete
+func (l *line) escape() {
+	r, _, err := l.e.in.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return
+	}
+	r, _, err = l.e.in.ReadRune()
+	if err != nil {
+		return
+	}
+
+	if r >= '0' && r <= '9' {
+		// numbered sequence such as ESC [ 3 ~
+		number := r
+		for r != '~' {
+			if r, _, err = l.e.in.ReadRune(); err != nil {
+				return
+			}
+		}
+		switch number {
+		case '1', '7':
+			l.pos = 0
+		case '3':
+			l.delete()
+		case '4', '8':
+			l.pos = len(l.buf)
+		}
+		return
+	}
+
+	switch r {
+	case 'A':
+		l.previous()
+	case 'B':
+		l.next()
+	case 'C':
+		l.right()
+	case 'D':
+		l.left()
+	case 'H':
+		l.pos = 0
+	case 'F':
+		l.pos = len(l.buf)
+	}
+}
+
+func (l *line) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+func (l *line) insertString(s string) {
+	for _, r := range s {
+		l.insert(r)
+	}
+}
+
+// delete : remove the rune under the cursor
+func (l *line) delete() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+func (l *line) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *line) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
+
+func (l *line) previous() {
+	if l.historyIndex == 0 {
+		return
+	}
+	if l.historyIndex == len(l.e.history) {
+		l.saved = append([]rune{}, l.buf...)
+	}
+	l.historyIndex--
+	l.buf = []rune(l.e.history[l.historyIndex])
+	l.pos = len(l.buf)
+}
+
+func (l *line) next() {
+	if l.historyIndex >= len(l.e.history) {
+		return
+	}
+	l.historyIndex++
+	if l.historyIndex == len(l.e.history) {
+		l.buf = l.saved
+	} else {
+		l.buf = []rune(l.e.history[l.historyIndex])
+	}
+	l.pos = len(l.buf)
+}
+
+// handleSearch : apply a key during a Ctrl-R search, reporting whether the
+// line is done and whether the key was used. Other keys end the search,
+// keeping the match, and are then applied to it; so does an escape sequence
+// such as an arrow, where a bare ESC cancels the search.
+func (l *line) handleSearch(r rune) (bool, bool) {
+	s := l.search
+
+	switch {
+	case r == escape && l.escapeSequence():
+		l.search = nil
+		return false, false
+	case r == ctrlR:
+		l.searchFrom(s.index - 1)
+	case r == ctrlH || r == backspace:
+		if len(s.query) > 0 {
+			s.query = s.query[:len(s.query)-1]
+		}
+		l.searchFrom(len(l.e.history) - 1)
+	case r == ctrlG || r == ctrlC || r == escape:
+		l.buf = s.saved
+		l.pos = len(l.buf)
+		l.search = nil
+	case r == '\r' || r == '\n':
+		return true, true
+	case unicode.IsPrint(r):
+		s.query = append(s.query, r)
+		l.searchFrom(s.index)
+	default:
+		l.search = nil
+		return false, false
+	}
+	return false, true
+}
+
+// searchFrom : find the newest history entry at or before index containing
+// the query, showing it as the line
+func (l *line) searchFrom(index int) {
+	s := l.search
+	if index >= len(l.e.history) {
+		index = len(l.e.history) - 1
+	}
+
+	query := string(s.query)
+	for i := index; i >= 0; i-- {
+		entry := l.e.history[i]
+		if at := strings.Index(entry, query); at >= 0 {
+			s.index = i
+			s.failed = false
+			l.buf = []rune(entry)
+			l.pos = len([]rune(entry[:at]))
+			return
+		}
+	}
+	s.failed = true
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == ':'
+}
+
+// wordStart : return where the completion word before the cursor starts
+func (l *line) wordStart() int {
+	start := l.pos
+	for start > 0 && isWordRune(l.buf[start-1]) {
+		start--
+	}
+	return start
+}
+
+// complete : complete the word before the cursor, listing the candidates
+// when they have no longer common prefix
+func (l *line) complete() {
+	if l.e.Complete == nil {
+		return
+	}
+
+	start := l.wordStart()
+	word := string(l.buf[start:l.pos])
+
+	var candidates []string
+	seen := map[string]bool{}
+	for _, c := range l.e.Complete(word) {
+		if strings.HasPrefix(c, word) && !seen[c] {
+			seen[c] = true
+			candidates = append(candidates, c)
+		}
+	}
+	sort.Strings(candidates)
+
+	switch {
+	case len(candidates) == 0:
+		io.WriteString(l.e.out, "\a")
+	case len(candidates) == 1:
+		l.insertString(candidates[0][len(word):])
+	default:
+		prefix := commonPrefix(candidates)
+		if len(prefix) > len(word) {
+			l.insertString(prefix[len(word):])
+			return
+		}
+		fmt.Fprintf(l.e.out, "\r\n%s\r\n", strings.Join(candidates, "  "))
+	}
+}
+
+func commonPrefix(words []string) string {
+	prefix := words[0]
+	for _, w := range words[1:] {
+		for !strings.HasPrefix(w, prefix) {
+			prefix = prefix[:len(prefix)-1]
+		}
+	}
+	return prefix
+}
+
+// refresh : redraw the prompt and line, placing the cursor
+func (l *line) refresh() {
+	if s := l.search; s != nil {
+		label := "reverse-i-search"
+		if s.failed {
+			label = "failed " + label
+		}
+		fmt.Fprintf(l.e.out, "\r(%s)`%s': %s\x1b[K", label, string(s.query), string(l.buf))
+		return
+	}
+
+	text := string(l.buf)
+	if l.e.Highlight != nil {
+		text = l.e.Highlight(text)
+	}
+	fmt.Fprintf(l.e.out, "\r%s%s\x1b[K", l.prompt, text)
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(l.e.out, "\x1b[%dD", back)
+	}
+}
@@ -0,0 +1,238 @@
+package editor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const (
+	up    = "\x1b[A"
+	down  = "\x1b[B"
+	right = "\x1b[C"
+	left  = "\x1b[D"
+	home  = "\x1b[H"
+	end   = "\x1b[F"
+	del   = "\x1b[3~"
+)
+
+func newTestEditor(keys string, history ...string) *Editor {
+	e := New(strings.NewReader(keys), io.Discard)
+	for _, h := range history {
+		e.AddHistory(h)
+	}
+	return e
+}
+
+func TestReadLineEditing(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"let x = 1\r", "let x = 1"},
+		{"ac" + left + "b\r", "abc"},
+		{"abc" + home + "x" + end + "y\r", "xabcy"},
+		{"abc\x01x\x05y\r", "xabcy"},
+		{"abc\x02\x02\x06z\r", "abzc"},
+		{"abcd\x7f\x7f\r", "ab"},
+		{"abcd" + left + left + del + "\r", "abd"},
+		{"abcd" + left + left + "\x04\r", "abd"},
+		{"abcd" + left + left + "\x0b\r", "ab"},
+		{"abcd" + left + left + "\x15\r", "cd"},
+		{"let foo bar\x17\r", "let foo "},
+		{"let foo  \x17\r", "let "},
+		{"héllo" + left + left + left + "\x7fe\r", "hello"},
+		{"ab\n", "ab"},
+		{"no newline", "no newline"},
+	}
+
+	for _, tt := range tests {
+		line, err := newTestEditor(tt.keys).ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestReadLineErrors(t *testing.T) {
+	if _, err := newTestEditor("\x04").ReadLine(">> "); err != io.EOF {
+		t.Errorf("Ctrl-D on empty line: expected io.EOF, got=%v", err)
+	}
+	if _, err := newTestEditor("ab\x03").ReadLine(">> "); err != ErrInterrupted {
+		t.Errorf("Ctrl-C: expected ErrInterrupted, got=%v", err)
+	}
+	if _, err := newTestEditor("").ReadLine(">> "); err != io.EOF {
+		t.Errorf("end of input: expected io.EOF, got=%v", err)
+	}
+}
+
+func TestHistoryNavigation(t *testing.T) {
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{up + "\r", "third"},
+		{up + up + "\r", "second"},
+		{up + up + up + up + "\r", "first"},
+		{"draft" + up + down + "\r", "draft"},
+		{up + up + down + "\r", "third"},
+		{"\x10\x10 2\r", "second 2"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, "first", "second", "third")
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestReverseSearch(t *testing.T) {
+	history := []string{"let add = fn(a, b) { a + b }", "add(1, 2)", "let x = 5", "add(x, 3)"}
+
+	tests := []struct {
+		keys     string
+		expected string
+	}{
+		{"\x12add\r", "add(x, 3)"},
+		{"\x12add\x12\r", "add(1, 2)"},
+		{"\x12add\x12\x12\r", "let add = fn(a, b) { a + b }"},
+		{"\x12let\x7f\x7f\x7fx =\r", "let x = 5"},
+		{"typed\x12zzz\x07\r", "typed"},
+		{"typed\x12add\x1b\r", "typed"},
+		{"\x123)\x1b[C0\r", "add(x, 30)"},
+		{"\x12x, \x05 + 1\r", "add(x, 3) + 1"},
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, history...)
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	words := []string{"let", "return", "result", "results", ":reset", ":env"}
+	complete := func(word string) []string { return words }
+
+	tests := []struct {
+		keys     string
+		expected string
+		listed   string
+	}{
+		{"le\t x\r", "let x", ""},
+		{"re\t\r", "re", "result  results  return"},
+		{"res\t\r", "result", ""},
+		{"f(resu\t)\r", "f(result)", ""},
+		{":r\t\r", ":reset", ""},
+		{"zz\t\r", "zz", ""},
+	}
+
+	for _, tt := range tests {
+		var out bytes.Buffer
+		e := New(strings.NewReader(tt.keys), &out)
+		e.Complete = complete
+
+		line, err := e.ReadLine(">> ")
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("%q: line wrong. expected=%q, got=%q", tt.keys, tt.expected, line)
+		}
+		if tt.listed != "" && !strings.Contains(out.String(), "\r\n"+tt.listed+"\r\n") {
+			t.Errorf("%q: candidates not listed. got=%q", tt.keys, out.String())
+		}
+	}
+}
+
+func TestHistoryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	e := New(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory of a missing file returned error: %s", err)
+	}
+	for _, line := range []string{"one", "two", "two", "  ", "three"} {
+		if err := e.AddHistory(line); err != nil {
+			t.Fatalf("AddHistory returned error: %s", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("history file wrong. got=%q", data)
+	}
+
+	reloaded := New(strings.NewReader(""), io.Discard)
+	if err := reloaded.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory returned error: %s", err)
+	}
+	if strings.Join(reloaded.History(), ",") != "one,two,three" {
+		t.Errorf("reloaded history wrong. got=%q", reloaded.History())
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+
+	var lines []string
+	for i := 0; i < MaxHistory+10; i++ {
+		lines = append(lines, strings.Repeat("x", i+1))
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	e := New(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("LoadHistory returned error: %s", err)
+	}
+	if len(e.History()) != MaxHistory || e.History()[0] != lines[10] {
+		t.Errorf("history not trimmed to the newest %d entries", MaxHistory)
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Count(string(data), "\n") != MaxHistory {
+		t.Errorf("history file not compacted")
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	var out bytes.Buffer
+	e := New(strings.NewReader("ab"+left+"\r"), &out)
+	e.Highlight = strings.ToUpper
+
+	line, err := e.ReadLine(">> ")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if line != "ab" {
+		t.Errorf("highlighting changed the line. got=%q", line)
+	}
+	if !strings.Contains(out.String(), ">> AB\x1b[K\x1b[1D") {
+		t.Errorf("line not drawn highlighted. got=%q", out.String())
+	}
+}
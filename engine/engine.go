@@ -0,0 +1,287 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/code"
+	"github.com/rockspore/monkey-interpreter/compiler"
+	"github.com/rockspore/monkey-interpreter/evaluator"
+	"github.com/rockspore/monkey-interpreter/object"
+	"github.com/rockspore/monkey-interpreter/optimizer"
+	"github.com/rockspore/monkey-interpreter/resolver"
+	"github.com/rockspore/monkey-interpreter/vm"
+)
+
+// ErrAborted : matched by errors.Is against the errors of runs and calls
+// stopped by their context or step budget, which also unwrap to the
+// context's error, see object.AbortError
+var ErrAborted = object.ErrAborted
+
+// Engine : executes parsed programs against a set of globals that persists
+// between runs
+type Engine interface {
+	// Run : execute the program, returning the value of its last statement
+	Run(program *ast.Program) (object.Object, error)
+	// RunContext : execute the program like Run, failing once ctx is done
+	RunContext(ctx context.Context, program *ast.Program) (object.Object, error)
+	// Call : call a function value, such as a global returned by Get
+	Call(fn object.Object, args ...object.Object) (object.Object, error)
+	// CallContext : call a function value like Call, failing once ctx is done
+	CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error)
+	// Get : return the value bound to a global name
+	Get(name string) (object.Object, bool)
+	// Set : bind a global name to a value
+	Set(name string, val object.Object)
+	// Globals : return the sorted names of all bound globals
+	Globals() []string
+	// Reset : drop all globals
+	Reset()
+	// SetLimits : bound the programs and calls run from now on
+	SetLimits(limits Limits)
+}
+
+// Limits : bounds on each run or call of an engine. Zero fields take the
+// defaults of the evaluator and the vm: no step budget and their default call
+// depth.
+type Limits struct {
+	MaxSteps     int // ast nodes evaluated or instructions executed
+	MaxCallDepth int // nested function calls before a stack overflow error
+}
+
+var constructors = map[string]func(...optimizer.Pass) Engine{
+	"eval": func(passes ...optimizer.Pass) Engine { return NewEval(passes...) },
+	"vm":   func(passes ...optimizer.Pass) Engine { return NewVM(passes...) },
+}
+
+// Names : return the names of all available engines
+func Names() []string {
+	names := []string{}
+	for name := range constructors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New : create a new engine by name, optimizing every program it runs with
+// the given passes
+func New(name string, passes ...optimizer.Pass) (Engine, error) {
+	constructor, ok := constructors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown engine %q", name)
+	}
+	return constructor(passes...), nil
+}
+
+// Eval : the tree-walking engine
+type Eval struct {
+	env    *object.Environment
+	passes []optimizer.Pass
+	limits Limits
+}
+
+// NewEval : create a new tree-walking engine
+func NewEval(passes ...optimizer.Pass) *Eval {
+	return &Eval{env: object.NewEnvironment(), passes: passes}
+}
+
+// Run : optimize, resolve and evaluate the program in the engine's
+// environment
+func (e *Eval) Run(program *ast.Program) (object.Object, error) {
+	return e.RunContext(context.Background(), program)
+}
+
+// RunContext : run the program, aborting once ctx is done
+func (e *Eval) RunContext(ctx context.Context, program *ast.Program) (object.Object, error) {
+	program = optimizer.Optimize(program, e.passes...)
+	resolver.Resolve(program)
+	result := evaluator.EvalContext(ctx, program, e.env, e.limits.MaxSteps, e.limits.MaxCallDepth)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj.AsError()
+	}
+	return result, nil
+}
+
+// Call : apply a function to arguments
+func (e *Eval) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return e.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext : apply a function to arguments, aborting once ctx is done
+func (e *Eval) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	result := evaluator.ApplyFunctionContext(ctx, fn, args, e.limits.MaxSteps, e.limits.MaxCallDepth)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errObj.AsError()
+	}
+	return result, nil
+}
+
+// Get : return the value bound to a global name
+func (e *Eval) Get(name string) (object.Object, bool) {
+	return e.env.Get(name)
+}
+
+// Set : bind a global name to a value
+func (e *Eval) Set(name string, val object.Object) {
+	e.env.Set(name, val)
+}
+
+// Globals : return the sorted names of all bound globals
+func (e *Eval) Globals() []string {
+	return e.env.Names()
+}
+
+// Reset : drop all globals
+func (e *Eval) Reset() {
+	e.env = object.NewEnvironment()
+}
+
+// SetLimits : bound the programs and calls run from now on
+func (e *Eval) SetLimits(limits Limits) {
+	e.limits = limits
+}
+
+// VM : the bytecode compiling engine. It resolves names when compiling, so
+// it differs from Eval inside functions:
+//   - closures capture the values of enclosing locals when they are created,
+//     so a later let of the same name in the enclosing function is not seen
+//   - a name a function refers to before it is bound is taken to be a global,
+//     so a later local of the enclosing function is not seen
+//   - a local whose let has not run in this call is not found, where Eval
+//     falls back to the binding of the same name in an enclosing scope
+//
+// Globals are shared by reference in both, so forward references and mutual
+// recursion between top-level functions work alike.
+type VM struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	passes      []optimizer.Pass
+	limits      Limits
+}
+
+// NewVM : create a new bytecode engine
+func NewVM(passes ...optimizer.Pass) *VM {
+	return &VM{
+		symbolTable: compiler.NewSymbolTable(),
+		constants:   []object.Object{},
+		globals:     make([]object.Object, vm.GlobalsSize),
+		passes:      passes,
+	}
+}
+
+// Run : optimize and compile the program and execute it on a fresh vm
+// sharing the engine's globals
+func (e *VM) Run(program *ast.Program) (object.Object, error) {
+	return e.RunContext(context.Background(), program)
+}
+
+// RunContext : run the program, aborting once ctx is done
+func (e *VM) RunContext(ctx context.Context, program *ast.Program) (object.Object, error) {
+	program = optimizer.Optimize(program, e.passes...)
+
+	// compile against a copy of the symbols, so that a program failing to
+	// compile defines nothing; globals whose let fails at runtime stay unset
+	// and are reported as not found
+	symbolTable := e.symbolTable.Clone()
+	comp := compiler.NewWithState(symbolTable, e.constants)
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+
+	bytecode := comp.Bytecode()
+	e.symbolTable = symbolTable
+	e.constants = bytecode.Constants
+
+	machine := vm.NewWithGlobalsStore(bytecode, e.globals)
+	machine.SetMaxCallDepth(e.limits.MaxCallDepth)
+	if err := machine.RunContext(ctx, e.limits.MaxSteps); err != nil {
+		return nil, err
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+// Compile : optimize and compile the program against the engine's globals
+// and constants as Run would, without running it or keeping its definitions
+func (e *VM) Compile(program *ast.Program) (*compiler.Bytecode, error) {
+	program = optimizer.Optimize(program, e.passes...)
+
+	constants := append([]object.Object{}, e.constants...)
+	comp := compiler.NewWithState(e.symbolTable.Clone(), constants)
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+	return comp.Bytecode(), nil
+}
+
+// Call : call a function on a fresh vm sharing the engine's globals, by
+// running bytecode that loads it and the arguments as constants appended to
+// the engine's own, which the function's instructions refer to
+func (e *VM) Call(fn object.Object, args ...object.Object) (object.Object, error) {
+	return e.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext : call a function, aborting once ctx is done
+func (e *VM) CallContext(ctx context.Context, fn object.Object, args ...object.Object) (object.Object, error) {
+	base := len(e.constants)
+	constants := make([]object.Object, 0, base+1+len(args))
+	constants = append(constants, e.constants...)
+	constants = append(constants, fn)
+	constants = append(constants, args...)
+
+	instructions := code.Make(code.OpConstant, base)
+	for i := range args {
+		instructions = append(instructions, code.Make(code.OpConstant, base+i+1)...)
+	}
+	instructions = append(instructions, code.Make(code.OpCall, len(args))...)
+	instructions = append(instructions, code.Make(code.OpPop)...)
+
+	bytecode := &compiler.Bytecode{Instructions: instructions, Constants: constants}
+	machine := vm.NewWithGlobalsStore(bytecode, e.globals)
+	machine.SetMaxCallDepth(e.limits.MaxCallDepth)
+	if err := machine.RunContext(ctx, e.limits.MaxSteps); err != nil {
+		return nil, err
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+// Get : return the value bound to a global name
+func (e *VM) Get(name string) (object.Object, bool) {
+	symbol, ok := e.symbolTable.Resolve(name)
+	if !ok || symbol.Scope != compiler.GlobalScope || e.globals[symbol.Index] == nil {
+		return nil, false
+	}
+	return e.globals[symbol.Index], true
+}
+
+// Set : bind a global name to a value
+func (e *VM) Set(name string, val object.Object) {
+	symbol := e.symbolTable.Define(name)
+	e.globals[symbol.Index] = val
+}
+
+// Globals : return the sorted names of all bound globals
+func (e *VM) Globals() []string {
+	names := []string{}
+	for _, name := range e.symbolTable.Names() {
+		if _, ok := e.Get(name); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Reset : drop all globals
+func (e *VM) Reset() {
+	e.symbolTable = compiler.NewSymbolTable()
+	e.constants = []object.Object{}
+	e.globals = make([]object.Object, vm.GlobalsSize)
+}
+
+// SetLimits : bound the programs and calls run from now on
+func (e *VM) SetLimits(limits Limits) {
+	e.limits = limits
+}
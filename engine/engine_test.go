@@ -0,0 +1,572 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/object"
+	"github.com/rockspore/monkey-interpreter/optimizer"
+	"github.com/rockspore/monkey-interpreter/parser"
+)
+
+// errorMessage : expected runtime or compile error of a conformance case
+type errorMessage string
+
+// conformanceTests : cases every engine must pass, lifted from the
+// evaluator's own tests
+var conformanceTests = []struct {
+	input    string
+	expected interface{} // int, bool, nil for NULL, or errorMessage
+}{
+	// integer expressions
+	{"5", 5},
+	{"-10", -10},
+	{"5 + 5 + 5 + 5 - 10", 10},
+	{"2 * 2 * 2 * 2 * 2", 32},
+	{"-50 + 100 + -50", 0},
+	{"20 + 2 * -10", 0},
+	{"50 / 2 * 2 + 10", 60},
+	{"2 * (5 + 10)", 30},
+	{"3 * (3 * 3) + 10", 37},
+	{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+
+	// boolean expressions
+	{"true", true},
+	{"false", false},
+	{"1 < 2", true},
+	{"1 > 2", false},
+	{"1 >= 1", true},
+	{"2 <= 1", false},
+	{"1 == 1", true},
+	{"1 != 2", true},
+	{"true == false", false},
+	{"false != true", true},
+	{"(1 < 2) == true", true},
+	{"(1 > 2) == true", false},
+
+	// bang operator
+	{"!true", false},
+	{"!false", true},
+	{"!5", false},
+	{"!!true", true},
+	{"!!5", true},
+
+	// if/else
+	{"if (true) { 10 }", 10},
+	{"if (false) { 10 }", nil},
+	{"if (1) { 10 }", 10},
+	{"if (1 > 2) { 10 }", nil},
+	{"if (1 > 2) { 10 } else { 20 }", 20},
+	{"if (1 < 2) { 10 } else { 20 }", 10},
+
+	// while
+	{"let i = 0; while (i < 10) { let i = i + 1; }; i;", 10},
+	{"while (false) { 1 }", nil},
+	{"let x = while (false) { 1 }; x", nil},
+	{"let i = 0; while (if (i < 2) { true }) { let i = i + 1; }; i", 2},
+	{"let n = 0; let i = 0; while (i < 3) { let j = 0; while (j < 4) { let n = n + 1; let j = j + 1; }; let i = i + 1; }; n", 12},
+	{"let f = fn() { let i = 0; let s = 0; while (i < 4) { let s = s + i; let i = i + 1; }; s }; f()", 6},
+	{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 5) { return i; } } }; f()", 5},
+	{"let i = 0; while (i < 3) { let i = i + true; }", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+
+	// return statements
+	{"return 10;", 10},
+	{"return 10; 9;", 10},
+	{"9; return 2 * 5; 9;", 10},
+	{"if (10 > 1) { if (10 > 1) { return 10; } return 1; }", 10},
+
+	// let statements
+	{"let a = 5 * 5; a;", 25},
+	{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+
+	// functions and closures
+	{"let identity = fn(x) { return x; }; identity(5);", 5},
+	{"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));", 20},
+	{"fn(x) { x; }(5)", 5},
+	{"let newAdder = fn(x) { fn(y) { x + y }; }; let addTwo = newAdder(2); addTwo(2);", 4},
+	{"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15);", 610},
+
+	// errors
+	{"5 + true; 5;", errorMessage("type mismatch: INTEGER + BOOLEAN")},
+	{"-true", errorMessage("unknown operator: -BOOLEAN")},
+	{"5; true + false; 5", errorMessage("unknown operator: BOOLEAN + BOOLEAN")},
+	{"if (10 > 1) { return true + false; }", errorMessage("unknown operator: BOOLEAN + BOOLEAN")},
+	{"foobar", errorMessage("identifier not found: foobar")},
+	{"let f = fn(x) { f(x + 1) }; f(0);", errorMessage("stack overflow: depth 10001")},
+	{"let f = fn(a, b) { a }; f(1);", errorMessage("wrong number of arguments: want=2, got=1")},
+	{"fn() { 1 }(1, 2)", errorMessage("wrong number of arguments: want=0, got=2")},
+	{"5(1)", errorMessage("not a function: INTEGER")},
+	{"let f = fn() { g() }; let g = fn() { 1 }; f()", 1},
+	{"let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; even(10)", true},
+	{"let f = fn() { h() }; f()", errorMessage("identifier not found: h")},
+	{"1 / 0", errorMessage("division by zero")},
+	{"let zero = fn() { 0 }; 10 / zero(); 5", errorMessage("division by zero")},
+	{"let f = fn(c) { if (c) { let x = 1; }; x + 1 }; f(false)", errorMessage("identifier not found: x")},
+	{"let f = fn(c) { if (c) { let x = 1; }; x }; f(false)", errorMessage("identifier not found: x")},
+	{"let g = fn(a, b, c) { a }; g(7, 8, 9); let f = fn(c) { if (c) { let y = 1; }; y }; f(false)", errorMessage("identifier not found: y")},
+}
+
+func TestConformance(t *testing.T) {
+	for _, name := range Names() {
+		testConformance(t, name)
+	}
+}
+
+func TestConformanceOptimized(t *testing.T) {
+	for _, name := range Names() {
+		testConformance(t, name, optimizer.DefaultPasses...)
+	}
+}
+
+func testConformance(t *testing.T, name string, passes ...optimizer.Pass) {
+	t.Helper()
+
+	for _, tt := range conformanceTests {
+		eng, err := New(name, passes...)
+		if err != nil {
+			t.Fatalf("New(%q) failed: %s", name, err)
+		}
+
+		p := parser.New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		if len(p.Errors()) != 0 {
+			t.Fatalf("parser errors for %q: %v", tt.input, p.Errors())
+		}
+
+		result, err := eng.Run(program)
+		testResult(t, name, tt.input, tt.expected, result, err)
+	}
+}
+
+func testResult(t *testing.T, engine string, input string, expected interface{},
+	result object.Object, err error) {
+	t.Helper()
+
+	if msg, ok := expected.(errorMessage); ok {
+		if err == nil || err.Error() != string(msg) {
+			t.Errorf("[%s] %s: wrong error. want=%q, got=%v", engine, input, msg, err)
+		}
+		return
+	}
+	if err != nil {
+		t.Errorf("[%s] %s: unexpected error: %s", engine, input, err)
+		return
+	}
+
+	switch expected := expected.(type) {
+	case int:
+		integer, ok := result.(*object.Integer)
+		if !ok || integer.Value != int64(expected) {
+			t.Errorf("[%s] %s: want=%d, got=%T (%+v)", engine, input, expected, result, result)
+		}
+	case bool:
+		boolean, ok := result.(*object.Boolean)
+		if !ok || boolean.Value != expected {
+			t.Errorf("[%s] %s: want=%t, got=%T (%+v)", engine, input, expected, result, result)
+		}
+	case nil:
+		if result == nil || result.Type() != object.NullOBJ {
+			t.Errorf("[%s] %s: want=NULL, got=%T (%+v)", engine, input, result, result)
+		}
+	}
+}
+
+// TestDivergence : pins the documented differences between the engines, see
+// VM
+func TestDivergence(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected map[string]interface{}
+	}{
+		{
+			"let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()",
+			map[string]interface{}{"eval": 2, "vm": 1},
+		},
+		{
+			"let f = fn() { let a = fn() { b() }; let b = fn() { 1 }; a() }; f()",
+			map[string]interface{}{"eval": 1, "vm": errorMessage("identifier not found: b")},
+		},
+		{
+			"let x = 1; let f = fn(c) { if (c) { let x = 10; }; x }; f(false)",
+			map[string]interface{}{"eval": 1, "vm": errorMessage("identifier not found: x")},
+		},
+		{
+			"let x = 1; let g = fn() { x }; let x = 2; g()",
+			map[string]interface{}{"eval": 2, "vm": 2},
+		},
+	}
+
+	for _, name := range Names() {
+		for _, tt := range tests {
+			eng, _ := New(name)
+			result, err := eng.Run(parser.New(lexer.New(tt.input)).ParseProgram())
+			testResult(t, name, tt.input, tt.expected[name], result, err)
+		}
+	}
+}
+
+func TestStatementsWithoutValue(t *testing.T) {
+	inputs := []string{"let x = 1;", "5; let x = 1;", ""}
+
+	for _, name := range Names() {
+		eng, _ := New(name)
+		for _, input := range inputs {
+			result, err := eng.Run(parser.New(lexer.New(input)).ParseProgram())
+			if err != nil || result != nil {
+				t.Errorf("[%s] %q: want no value, got=%v, %v", name, input, result, err)
+			}
+		}
+	}
+}
+
+func TestFailedRunsDefineNothing(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"let x = 1 / 0;", "division by zero"},
+		{"let x = y;", "identifier not found: y"},
+	}
+
+	for _, name := range Names() {
+		for _, tt := range tests {
+			eng, _ := New(name)
+			if _, err := eng.Run(parser.New(lexer.New(tt.input)).ParseProgram()); err == nil || err.Error() != tt.want {
+				t.Fatalf("[%s] %s: want error %q, got=%v", name, tt.input, tt.want, err)
+			}
+			if _, ok := eng.Get("x"); ok {
+				t.Errorf("[%s] %s: x is bound after a failed run", name, tt.input)
+			}
+
+			_, err := eng.Run(parser.New(lexer.New("x")).ParseProgram())
+			if err == nil || err.Error() != "identifier not found: x" {
+				t.Errorf("[%s] %s: want x not found, got=%v", name, tt.input, err)
+			}
+		}
+	}
+}
+
+func TestGlobalsPersistBetweenRuns(t *testing.T) {
+	for _, name := range Names() {
+		eng, _ := New(name)
+		eng.Set("x", &object.Integer{Value: 40})
+
+		for _, input := range []string{"let y = x + 1;", "let z = y + 1;"} {
+			if _, err := eng.Run(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+				t.Fatalf("[%s] %s: unexpected error: %s", name, input, err)
+			}
+		}
+
+		z, ok := eng.Get("z")
+		if !ok {
+			t.Fatalf("[%s] global z not found", name)
+		}
+		testResult(t, name, "z", 42, z, nil)
+
+		if _, ok := eng.Get("missing"); ok {
+			t.Errorf("[%s] global missing found, but was never set", name)
+		}
+	}
+}
+
+func TestGlobalsAndReset(t *testing.T) {
+	for _, name := range Names() {
+		eng, _ := New(name)
+		eng.Set("b", &object.Integer{Value: 1})
+
+		input := "let a = fn(x) { let local = x; local }; a(b)"
+		if _, err := eng.Run(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+			t.Fatalf("[%s] %s: unexpected error: %s", name, input, err)
+		}
+
+		if globals := fmt.Sprint(eng.Globals()); globals != "[a b]" {
+			t.Errorf("[%s] globals wrong. want=[a b], got=%s", name, globals)
+		}
+
+		eng.Reset()
+		if globals := eng.Globals(); len(globals) != 0 {
+			t.Errorf("[%s] globals left after reset: %v", name, globals)
+		}
+		if _, ok := eng.Get("a"); ok {
+			t.Errorf("[%s] global a found after reset", name)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	for _, name := range Names() {
+		eng, _ := New(name, optimizer.DefaultPasses...)
+
+		input := "let base = 10; let add = fn(x, y) { base + x + y };"
+		if _, err := eng.Run(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+			t.Fatalf("[%s] %s: unexpected error: %s", name, input, err)
+		}
+		add, _ := eng.Get("add")
+
+		result, err := eng.Call(add, &object.Integer{Value: 1}, &object.Integer{Value: 2})
+		testResult(t, name, "add(1, 2)", 13, result, err)
+
+		result, err = eng.Call(add, &object.Integer{Value: 1})
+		testResult(t, name, "add(1)", errorMessage("wrong number of arguments: want=2, got=1"), result, err)
+
+		result, err = eng.Call(&object.Integer{Value: 1})
+		testResult(t, name, "1()", errorMessage("not a function: INTEGER"), result, err)
+
+		// literals in the body are constants of the engine's own pool
+		input = "let scale = fn(x) { if (x > 100) { 100 } else { x * 3 + 7 } };"
+		if _, err := eng.Run(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+			t.Fatalf("[%s] %s: unexpected error: %s", name, input, err)
+		}
+		scale, _ := eng.Get("scale")
+
+		result, err = eng.Call(scale, &object.Integer{Value: 5})
+		testResult(t, name, "scale(5)", 22, result, err)
+		result, err = eng.Call(scale, &object.Integer{Value: 500})
+		testResult(t, name, "scale(500)", 100, result, err)
+	}
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	countdown := "let f = fn(x) { if (x == 0) { 0 } else { 1 + f(x - 1) } };"
+
+	for _, name := range Names() {
+		limited, _ := New(name)
+		limited.SetLimits(Limits{MaxCallDepth: 50})
+		unlimited, _ := New(name)
+
+		for _, eng := range []Engine{limited, unlimited} {
+			if _, err := eng.Run(parser.New(lexer.New(countdown)).ParseProgram()); err != nil {
+				t.Fatalf("[%s] unexpected error: %s", name, err)
+			}
+		}
+
+		result, err := limited.Run(parser.New(lexer.New("f(49)")).ParseProgram())
+		testResult(t, name, "f(49)", 49, result, err)
+		result, err = limited.Run(parser.New(lexer.New("f(50)")).ParseProgram())
+		testResult(t, name, "f(50)", errorMessage("stack overflow: depth 51"), result, err)
+		result, err = unlimited.Run(parser.New(lexer.New("f(50)")).ParseProgram())
+		testResult(t, name, "f(50)", 50, result, err)
+
+		f, _ := limited.Get("f")
+		result, err = limited.Call(f, &object.Integer{Value: 50})
+		testResult(t, name, "Call(f, 50)", errorMessage("stack overflow: depth 51"), result, err)
+	}
+}
+
+// TestStackOverflowRecovers : a runaway recursion at the default depth fails
+// with an error and leaves the engine usable
+func TestStackOverflowRecovers(t *testing.T) {
+	for _, name := range Names() {
+		eng, _ := New(name)
+		result, err := eng.Run(parser.New(lexer.New("let f = fn(x) { 1 + f(x + 1) }; f(0)")).ParseProgram())
+		testResult(t, name, "f(0)", errorMessage("stack overflow: depth 10001"), result, err)
+
+		result, err = eng.Run(parser.New(lexer.New("let g = fn(x) { if (x == 0) { 0 } else { 1 + g(x - 1) } }; g(100)")).ParseProgram())
+		testResult(t, name, "g(100)", 100, result, err)
+	}
+}
+
+func TestRunContextLimits(t *testing.T) {
+	loop := "while (true) { 1 }"
+	fib := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(40);"
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		ctx      context.Context
+		timeout  time.Duration
+		input    string
+		maxSteps int
+		expected string
+		cause    error // context error the abort wraps, if any
+	}{
+		{context.Background(), 0, loop, 1000, "step budget exceeded: 1000", nil},
+		{context.Background(), 0, fib, 1000, "step budget exceeded: 1000", nil},
+		{cancelled, 0, loop, 0, "evaluation cancelled: context canceled", context.Canceled},
+		{context.Background(), 10 * time.Millisecond, loop, 0, "evaluation cancelled: context deadline exceeded", context.DeadlineExceeded},
+		{context.Background(), 10 * time.Millisecond, fib, 0, "evaluation cancelled: context deadline exceeded", context.DeadlineExceeded},
+	}
+
+	for _, name := range Names() {
+		for _, tt := range tests {
+			eng, _ := New(name)
+			eng.SetLimits(Limits{MaxSteps: tt.maxSteps})
+
+			ctx := tt.ctx
+			if tt.timeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.timeout)
+				defer cancel()
+			}
+
+			_, err := eng.RunContext(ctx, parser.New(lexer.New(tt.input)).ParseProgram())
+			if err == nil || err.Error() != tt.expected {
+				t.Errorf("[%s] %s: want error %q, got=%v", name, tt.input, tt.expected, err)
+			}
+			if !errors.Is(err, ErrAborted) {
+				t.Errorf("[%s] %s: error %v is not ErrAborted", name, tt.input, err)
+			}
+			if tt.cause != nil && !errors.Is(err, tt.cause) {
+				t.Errorf("[%s] %s: error %v does not wrap %v", name, tt.input, err, tt.cause)
+			}
+		}
+	}
+}
+
+func TestCallContext(t *testing.T) {
+	for _, name := range Names() {
+		eng, _ := New(name)
+		input := "let spin = fn() { while (true) { 1 } };"
+		if _, err := eng.Run(parser.New(lexer.New(input)).ParseProgram()); err != nil {
+			t.Fatalf("[%s] %s: unexpected error: %s", name, input, err)
+		}
+		spin, _ := eng.Get("spin")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		_, err := eng.CallContext(ctx, spin)
+		cancel()
+		if err == nil || err.Error() != "evaluation cancelled: context deadline exceeded" {
+			t.Errorf("[%s] CallContext(spin) error wrong. got=%v", name, err)
+		}
+		if !errors.Is(err, ErrAborted) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("[%s] CallContext(spin) error is not an aborted deadline. got=%v", name, err)
+		}
+
+		eng.SetLimits(Limits{MaxSteps: 500})
+		if _, err := eng.Call(spin); err == nil || err.Error() != "step budget exceeded: 500" || !errors.Is(err, ErrAborted) {
+			t.Errorf("[%s] Call(spin) error wrong. got=%v", name, err)
+		}
+	}
+}
+
+func TestBuiltin(t *testing.T) {
+	double := &object.Builtin{Name: "double", Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 || args[0].Type() != object.IntegerOBJ {
+			return &object.Error{Message: "double takes an integer"}
+		}
+		return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+	}}
+	isZero := &object.Builtin{Name: "isZero", Fn: func(args ...object.Object) object.Object {
+		return &object.Boolean{Value: args[0].(*object.Integer).Value == 0}
+	}}
+	nothing := &object.Builtin{Name: "nothing", Fn: func(args ...object.Object) object.Object {
+		return nil
+	}}
+
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"double(21)", 42},
+		{"let f = fn(x) { double(x) + 1 }; f(2)", 5},
+		{"double(true)", errorMessage("double takes an integer")},
+		{"isZero(0) == true", true},
+		{"if (isZero(1)) { 1 } else { 2 }", 2},
+		{"nothing()", nil},
+	}
+
+	for _, name := range Names() {
+		for _, tt := range tests {
+			eng, _ := New(name)
+			eng.Set("double", double)
+			eng.Set("isZero", isZero)
+			eng.Set("nothing", nothing)
+
+			result, err := eng.Run(parser.New(lexer.New(tt.input)).ParseProgram())
+			testResult(t, name, tt.input, tt.expected, result, err)
+		}
+
+		eng, _ := New(name)
+		result, err := eng.Call(double, &object.Integer{Value: 4})
+		testResult(t, name, "double(4)", 8, result, err)
+	}
+}
+
+// hostRequest : a host object with attributes and methods
+type hostRequest struct {
+	status int64
+}
+
+func (r *hostRequest) Type() object.ObjectType { return "REQUEST" }
+func (r *hostRequest) Inspect() string         { return fmt.Sprintf("request(%d)", r.status) }
+
+func (r *hostRequest) GetAttr(name string) (object.Object, bool) {
+	switch name {
+	case "status":
+		return &object.Integer{Value: r.status}, true
+	case "ok":
+		return &object.Boolean{Value: r.status < 400}, true
+	case "double":
+		return &object.Builtin{Name: "double", Fn: func(args ...object.Object) object.Object {
+			return &object.Integer{Value: args[0].(*object.Integer).Value * 2}
+		}}, true
+	}
+	return nil, false
+}
+
+func (r *hostRequest) SetAttr(name string, val object.Object) error {
+	status, ok := val.(*object.Integer)
+	if name != "status" || !ok {
+		return fmt.Errorf("cannot set %s to %s", name, val.Type())
+	}
+	r.status = status.Value
+	return nil
+}
+
+func (r *hostRequest) CallMethod(name string, args ...object.Object) (object.Object, bool) {
+	switch name {
+	case "scaled":
+		return &object.Integer{Value: r.status * args[0].(*object.Integer).Value}, true
+	case "reset":
+		r.status = 200
+		return nil, true
+	}
+	return nil, false
+}
+
+func TestHostObjects(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"req.status", 200},
+		{"req.ok", true},
+		{"req.ok == true", true},
+		{"if (req.ok) { 1 } else { 2 }", 1},
+		{"req.status = 404", 404},
+		{"req.status = 500; req.ok", false},
+		{"let r = req; r.status = req.status + 1; req.status", 201},
+		{"req.scaled(3)", 600},
+		{"let f = fn(r) { r.scaled(2) + 1 }; f(req)", 401},
+		{"req.double(21)", 42},
+		{"req.status = 1; req.reset()", nil},
+		{"req.status = 1; req.reset(); req.status", 200},
+		{"req.missing", errorMessage("unknown attribute: REQUEST.missing")},
+		{"req.missing()", errorMessage("unknown method: REQUEST.missing")},
+		{"req.status()", errorMessage("not a function: INTEGER")},
+		{"req.status = true", errorMessage("cannot set status to BOOLEAN")},
+		{"let a = 1; a.x = 2", errorMessage("cannot set attribute: INTEGER.x")},
+		{"true.x", errorMessage("unknown attribute: BOOLEAN.x")},
+	}
+
+	for _, name := range Names() {
+		for _, tt := range tests {
+			eng, _ := New(name, optimizer.DefaultPasses...)
+			eng.Set("req", &hostRequest{status: 200})
+
+			result, err := eng.Run(parser.New(lexer.New(tt.input)).ParseProgram())
+			testResult(t, name, tt.input, tt.expected, result, err)
+		}
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := New("jit"); err == nil {
+		t.Errorf("expected error for unknown engine")
+	}
+}
@@ -1,6 +1,7 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/rockspore/monkey-interpreter/ast"
@@ -13,32 +14,86 @@ var (
 	FALSE = &object.Boolean{Value: false}
 )
 
+// DefaultMaxCallDepth : the maximum number of nested function calls before
+// evaluation is aborted with a stack overflow error, unless a run sets its own
+const DefaultMaxCallDepth = 10000
+
+// evaluation : per-run state shared by all nodes evaluated by one call to
+// EvalContext
+type evaluation struct {
+	ctx          context.Context
+	maxSteps     int
+	maxCallDepth int
+	steps        int
+}
+
+func newEvaluation(ctx context.Context, maxSteps int, maxCallDepth int) *evaluation {
+	if maxCallDepth <= 0 {
+		maxCallDepth = DefaultMaxCallDepth
+	}
+	return &evaluation{ctx: ctx, maxSteps: maxSteps, maxCallDepth: maxCallDepth}
+}
+
+// Concurrency : the evaluator keeps no state between calls and only reads
+// the ast, so several goroutines may evaluate at once, even the same program,
+// provided that
+//   - each goroutine evaluates in an environment of its own, usually one
+//     created with object.NewEnclosedEnvironment around a shared environment
+//     holding the globals, so that top-level lets stay private to the run
+//   - programs are optimized and resolved before they are shared, since both
+//     rewrite the ast
+//
+// Environments lock their bindings, so the shared globals may still be set
+// while runs read them, and closures may be called from several goroutines.
+// Host functions and objects must be safe for concurrent use themselves.
+// Engines, which optimize, resolve and bind globals on every run, are not
+// safe for concurrent use; monkey.Interpreter serializes its calls instead.
+
 // Eval : evaluate an ast node
 func Eval(node ast.Node, env *object.Environment) object.Object {
+	return EvalContext(context.Background(), node, env, 0, 0)
+}
+
+// EvalContext : evaluate an ast node, aborting with an error object once ctx
+// is done or more than maxSteps nodes have been evaluated (0 means no limit).
+// Limits are checked on every loop iteration and function call. Calls nested
+// deeper than maxCallDepth fail with a stack overflow (0 means
+// DefaultMaxCallDepth).
+func EvalContext(ctx context.Context, node ast.Node, env *object.Environment, maxSteps int, maxCallDepth int) object.Object {
+	return newEvaluation(ctx, maxSteps, maxCallDepth).eval(node, env)
+}
+
+func (e *evaluation) eval(node ast.Node, env *object.Environment) object.Object {
+	e.steps++
+
 	switch node := node.(type) {
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
-		env.Set(node.Name.Value, val)
+		if node.Name.Resolved {
+			env.SetSlot(node.Name.Slot, val)
+		} else {
+			env.Set(node.Name.Value, val)
+		}
 
 	// Expressions
 	case *ast.IntegerLiteral:
@@ -51,52 +106,80 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		return evalIdentifier(node, env)
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env) // recursively evaluate the right part
+		right := e.eval(node.Right, env) // recursively evaluate the right part
 		if isError(right) {
 			return right
 		}
 		return evalPrefixExpression(node.Operator, right)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 		return evalInfixExpression(node.Operator, left, right)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
+
+	case *ast.WhileExpression:
+		return e.evalWhileExpression(node, env)
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Body: body, Env: env}
+		return &object.Function{Parameters: params, Body: body, Locals: node.Locals, Env: env}
+
+	case *ast.AttributeExpression:
+		obj := e.eval(node.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		return hostResult(object.GetAttr(obj, node.Name))
+
+	case *ast.AssignExpression:
+		obj := e.eval(node.Target.Object, env)
+		if isError(obj) {
+			return obj
+		}
+		val := e.eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if err := object.SetAttr(obj, node.Target.Name, val); err != nil {
+			return err
+		}
+		return val
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if attr, ok := node.Function.(*ast.AttributeExpression); ok {
+			return e.evalMethodCall(attr, node.Arguments, env)
+		}
+
+		function := e.eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return e.applyFunction(function, args, env)
 	}
 
 	return nil
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *evaluation) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range program.Statements {
-		result = Eval(stmt, env)
+		result = e.eval(stmt, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -109,11 +192,11 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *evaluation) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, stmt := range block.Statements {
-		result = Eval(stmt, env)
+		result = e.eval(stmt, env)
 
 		if result != nil {
 			rt := result.Type()
@@ -126,11 +209,11 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *evaluation) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -140,19 +223,106 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalMethodCall : call a method of a host object, or else a function held
+// in one of its attributes
+func (e *evaluation) evalMethodCall(attr *ast.AttributeExpression, arguments []ast.Expression, env *object.Environment) object.Object {
+	obj := e.eval(attr.Object, env)
+	if isError(obj) {
+		return obj
+	}
+	args := e.evalExpressions(arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+
+	if err := e.checkLimits(); err != nil {
+		return err
+	}
+
+	result, called := object.LookupMethod(obj, attr.Name, args)
+	if called {
+		return hostResult(result)
+	}
+	if isError(result) {
+		return result
+	}
+	return e.applyFunction(result, args, env)
+}
+
+// ApplyFunction : call a function value with arguments from outside a
+// program, returning its result or an error object
+func ApplyFunction(fn object.Object, args []object.Object) object.Object {
+	return ApplyFunctionContext(context.Background(), fn, args, 0, 0)
+}
+
+// ApplyFunctionContext : call a function value like ApplyFunction, within
+// the limits of EvalContext
+func ApplyFunctionContext(ctx context.Context, fn object.Object, args []object.Object, maxSteps int, maxCallDepth int) object.Object {
+	e := newEvaluation(ctx, maxSteps, maxCallDepth)
+
+	caller := object.NewEnvironment()
+	if function, ok := fn.(*object.Function); ok {
+		caller = function.Env
+	}
+	return e.applyFunction(fn, args, caller)
+}
+
+func (e *evaluation) applyFunction(fn object.Object, args []object.Object, caller *object.Environment) object.Object {
+	if err := e.checkLimits(); err != nil {
+		return err
+	}
+
+	if builtin, ok := fn.(*object.Builtin); ok {
+		return applyBuiltin(builtin, args)
+	}
+
 	function, ok := fn.(*object.Function)
 	if !ok {
 		return newError("not a function: %s", fn.Type())
 	}
 
-	extendedEnv := extendedFunctionEnv(function, args)
-	evaluated := Eval(function.Body, extendedEnv)
+	if len(args) != len(function.Parameters) {
+		return newError("wrong number of arguments: want=%d, got=%d",
+			len(function.Parameters), len(args))
+	}
+
+	depth := caller.Depth() + 1
+	if depth > e.maxCallDepth {
+		return newError("stack overflow: depth %d", depth)
+	}
+
+	extendedEnv := extendedFunctionEnv(function, args, depth)
+	evaluated := e.eval(function.Body, extendedEnv)
 	return unwrapReturnValue(evaluated)
 }
 
-func extendedFunctionEnv(fn *object.Function, args []object.Object) *object.Environment {
-	env := object.NewEnclosedEnvironment(fn.Env)
+func applyBuiltin(builtin *object.Builtin, args []object.Object) object.Object {
+	return hostResult(builtin.Fn(args...))
+}
+
+// hostResult : map the null and boolean results of host functions and
+// methods to the evaluator's singletons
+func hostResult(result object.Object) object.Object {
+	switch result := result.(type) {
+	case nil, *object.Null:
+		return NULL
+	case *object.Boolean:
+		return nativeBoolToBooleanObject(result.Value)
+	default:
+		return result
+	}
+}
+
+func extendedFunctionEnv(fn *object.Function, args []object.Object, depth int) *object.Environment {
+	if fn.Locals != nil {
+		env := object.NewSlotEnvironment(fn.Env, depth, fn.Locals)
+		for paramIdx := range fn.Parameters {
+			env.SetSlot(paramIdx, args[paramIdx])
+		}
+		return env
+	}
+
+	env := object.NewCallEnvironment(fn.Env, depth)
 
 	for paramIdx, param := range fn.Parameters {
 		env.Set(param.Value, args[paramIdx])
@@ -238,6 +408,9 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 	case "*":
 		return &object.Integer{Value: leftVal * rightVal}
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return &object.Integer{Value: leftVal / rightVal}
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
@@ -258,6 +431,14 @@ func evalIntegerInfixExpression(operator string, left object.Object, right objec
 }
 
 func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+	if node.Resolved {
+		if val := env.GetSlot(node.Depth, node.Slot); val != nil {
+			return val
+		}
+		// the slot's let has not run yet, so the name still refers to an
+		// outer binding, as it would without resolution
+	}
+
 	val, ok := env.Get(node.Value)
 	if !ok {
 		return newError("identifier not found: " + node.Value)
@@ -266,19 +447,62 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	return val
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *evaluation) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	}
 	return NULL
 }
 
+func (e *evaluation) evalWhileExpression(we *ast.WhileExpression, env *object.Environment) object.Object {
+	for {
+		if err := e.checkLimits(); err != nil {
+			return err
+		}
+
+		condition := e.eval(we.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return NULL
+		}
+
+		result := e.eval(we.Consequence, env)
+		if result != nil {
+			rt := result.Type()
+			if rt == object.ReturnValueOBJ || rt == object.ErrorOBJ {
+				return result
+			}
+		}
+	}
+}
+
+// checkLimits : return an aborting error once the context is done or the
+// step budget is used up
+func (e *evaluation) checkLimits() *object.Error {
+	if e.maxSteps > 0 && e.steps > e.maxSteps {
+		return &object.Error{
+			Message: fmt.Sprintf("step budget exceeded: %d", e.maxSteps),
+			Aborted: true,
+		}
+	}
+	if err := e.ctx.Err(); err != nil {
+		return &object.Error{
+			Message: fmt.Sprintf("evaluation cancelled: %s", err),
+			Aborted: true,
+			Cause:   err,
+		}
+	}
+	return nil
+}
+
 func isTruthy(obj object.Object) bool {
 	switch obj {
 	case NULL:
@@ -1,11 +1,16 @@
 package evaluator
 
 import (
+	"context"
+	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/rockspore/monkey-interpreter/lexer"
 	"github.com/rockspore/monkey-interpreter/object"
 	"github.com/rockspore/monkey-interpreter/parser"
+	"github.com/rockspore/monkey-interpreter/resolver"
 )
 
 func TestEvalIntegerExpression(t *testing.T) {
@@ -228,6 +233,14 @@ if (10 > 1) {
 			"foobar",
 			"identifier not found: foobar",
 		},
+		{
+			"let f = fn(a, b) { a }; f(1);",
+			"wrong number of arguments: want=2, got=1",
+		},
+		{
+			"let x = 0; 1 / x",
+			"division by zero",
+		},
 	}
 
 	for _, tt := range tests {
@@ -314,3 +327,230 @@ let addTwo = newAdder(2); addTwo(2);`
 
 	testIntegerObject(t, testEval(input), 4)
 }
+
+func TestRecursionDepthLimit(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let f = fn(x) { f(x + 1) }; f(0);", "stack overflow: depth 101"},
+		{"let f = fn(x) { 1 + f(x) }; f(0);", "stack overflow: depth 101"},
+		{"let f = fn(x) { if (x == 0) { 0 } else { 1 + f(x - 1) } }; f(99);", 99},
+		{"let f = fn(x) { if (x == 0) { 0 } else { 1 + f(x - 1) } }; f(100);",
+			"stack overflow: depth 101"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), 0, 100)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("no error object returned. got=%T(%+v)",
+					evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q",
+					expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestWhileExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 10) { let i = i + 1; }; i;", 10},
+		{"let i = 0; while (false) { let i = i + 1; }; i;", 0},
+		{"while (false) { 1 }", nil},
+		{"let f = fn() { let i = 0; while (true) { let i = i + 1; if (i == 5) { return i; } } }; f();", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		integer, ok := tt.expected.(int)
+		if ok {
+			testIntegerObject(t, evaluated, int64(integer))
+		} else {
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestEvalContextLimits(t *testing.T) {
+	loop := "while (true) { 1 }"
+	fib := "let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(40);"
+
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, cancelTimeout := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancelTimeout()
+
+	tests := []struct {
+		ctx             context.Context
+		input           string
+		maxSteps        int
+		expectedMessage string
+	}{
+		{context.Background(), loop, 1000, "step budget exceeded: 1000"},
+		{context.Background(), fib, 1000, "step budget exceeded: 1000"},
+		{cancelled, loop, 0, "evaluation cancelled: context canceled"},
+		{timeout, loop, 0, "evaluation cancelled: context deadline exceeded"},
+		{timeout, fib, 0, "evaluation cancelled: context deadline exceeded"},
+	}
+
+	for _, tt := range tests {
+		program := parser.New(lexer.New(tt.input)).ParseProgram()
+		evaluated := EvalContext(tt.ctx, program, object.NewEnvironment(), tt.maxSteps, 0)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)",
+				evaluated, evaluated)
+			continue
+		}
+		if !errObj.Aborted {
+			t.Errorf("error object is not marked as aborted: %q", errObj.Message)
+		}
+		if errObj.Message != tt.expectedMessage {
+			t.Errorf("wrong error message. expected=%q, got=%q",
+				tt.expectedMessage, errObj.Message)
+		}
+	}
+}
+
+func TestEvalContextWithinLimits(t *testing.T) {
+	input := "let i = 0; while (i < 10) { let i = i + 1; }; i;"
+	program := parser.New(lexer.New(input)).ParseProgram()
+
+	evaluated := EvalContext(context.Background(), program, object.NewEnvironment(), 1000, 0)
+	testIntegerObject(t, evaluated, 10)
+}
+
+func TestResolvedEvaluation(t *testing.T) {
+	tests := []string{
+		"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+		"let newAdder = fn(x) { fn(y) { x + y }; }; newAdder(2)(3);",
+		"let f = fn(a) { let b = a * 2; let b = b + 1; b }; f(3);",
+		"let x = 1; let f = fn() { let y = x; let x = 2; y + x }; f();",
+		"let x = 1; let f = fn(c) { if (c) { let x = 10; }; x }; f(false) + f(true);",
+		"let f = fn() { let i = 0; let s = 0; while (i < 5) { let s = s + i; let i = i + 1; }; s }; f();",
+		"let f = fn(n) { fn() { let n = n + 1; n } }; let g = f(1); g() + g();",
+		"let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } }; fib(15);",
+		"let f = fn(x) { fn(y) { fn(z) { x + y + z } } }; f(1)(2)(3);",
+		"let f = fn(x) { x + undefined }; f(1);",
+	}
+
+	for _, input := range tests {
+		unresolved := testEval(input)
+
+		program := parser.New(lexer.New(input)).ParseProgram()
+		resolver.Resolve(program)
+		resolved := Eval(program, object.NewEnvironment())
+
+		if unresolved.Inspect() != resolved.Inspect() {
+			t.Errorf("%s: resolved evaluation differs. want=%s, got=%s",
+				input, unresolved.Inspect(), resolved.Inspect())
+		}
+	}
+}
+
+// TestConcurrentEvaluation : goroutines running programs in their own
+// environments around shared globals, meant to be run with -race
+func TestConcurrentEvaluation(t *testing.T) {
+	globals := object.NewEnvironment()
+	setup := parser.New(lexer.New(`
+let base = 100;
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+let adder = fn(x) { fn(y) { x + y } };
+let addTen = adder(10);`)).ParseProgram()
+	resolver.Resolve(setup)
+	if result := Eval(setup, globals); isError(result) {
+		t.Fatalf("setup failed: %s", result.Inspect())
+	}
+
+	// one program shared by all runs, resolved before it is shared
+	shared := parser.New(lexer.New(
+		"let sum = fn(n) { let s = 0; while (n > 0) { let s = s + n; let n = n - 1; }; s }; sum(100)",
+	)).ParseProgram()
+	resolver.Resolve(shared)
+
+	const runs = 16
+	errs := make(chan string, 3*runs)
+	done := make(chan struct{})
+
+	// a host keeps setting a global while the runs read others
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			globals.Set("counter", &object.Integer{Value: int64(i)})
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			env := object.NewEnclosedEnvironment(globals)
+
+			input := fmt.Sprintf("let base = %d; let x = fib(10) + addTen(base); x", i)
+			program := parser.New(lexer.New(input)).ParseProgram()
+			resolver.Resolve(program)
+
+			result, ok := Eval(program, env).(*object.Integer)
+			if !ok || result.Value != int64(55+10+i) {
+				errs <- fmt.Sprintf("run %d: wrong result %v", i, result)
+			}
+			if x, ok := env.Get("x"); !ok || x.Inspect() != fmt.Sprint(55+10+i) {
+				errs <- fmt.Sprintf("run %d: x not bound in its own environment", i)
+			}
+
+			result, ok = Eval(shared, object.NewEnclosedEnvironment(globals)).(*object.Integer)
+			if !ok || result.Value != 5050 {
+				errs <- fmt.Sprintf("run %d: wrong shared program result %v", i, result)
+			}
+		}(i)
+	}
+	wg.Wait()
+	<-done
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	if base, _ := globals.Get("base"); base.Inspect() != "100" {
+		t.Errorf("run changed the shared base. got=%s", base.Inspect())
+	}
+	if _, ok := globals.Get("x"); ok {
+		t.Errorf("run leaked x into the shared globals")
+	}
+}
+
+const fibInput = `
+let fib = fn(n) { if (n < 2) { n } else { fib(n - 1) + fib(n - 2) } };
+fib(25);`
+
+func BenchmarkFib25(b *testing.B) {
+	program := parser.New(lexer.New(fibInput)).ParseProgram()
+
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
+
+func BenchmarkFib25Resolved(b *testing.B) {
+	program := parser.New(lexer.New(fibInput)).ParseProgram()
+	resolver.Resolve(program)
+
+	for i := 0; i < b.N; i++ {
+		Eval(program, object.NewEnvironment())
+	}
+}
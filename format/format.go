@@ -0,0 +1,359 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/parser"
+)
+
+// MaxWidth : call arguments are broken onto separate lines when the call
+// would otherwise extend past this column
+const MaxWidth = 80
+
+// tabWidth : the width of an indentation tab when measuring columns
+const tabWidth = 4
+
+// Source : parse src and return it in canonical format
+func Source(src string) (string, error) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return "", fmt.Errorf("parse errors:\n\t%s", strings.Join(p.Errors(), "\n\t"))
+	}
+
+	return Program(program, l.Comments()), nil
+}
+
+// Program : return the canonical source of a program. Blocks are indented
+// with tabs, every statement ends with a semicolon, redundant parentheses are
+// dropped and single blank lines between statements are kept. Comments are
+// placed before the statement following them in the source, or at the end
+// of the line they trailed.
+func Program(program *ast.Program, comments []lexer.Comment) string {
+	p := &printer{comments: comments}
+	p.statements(program.Statements, 0, int(^uint(0)>>1))
+	return p.out.String()
+}
+
+// printer : writes canonical source, interleaving the pending comments
+type printer struct {
+	out      bytes.Buffer
+	comments []lexer.Comment
+
+	lastLine     int  // last source line printed
+	atBlockStart bool // nothing printed since the last opening brace
+}
+
+func (p *printer) write(s string) {
+	p.out.WriteString(s)
+}
+
+func (p *printer) indent(level int) {
+	p.write(strings.Repeat("\t", level))
+}
+
+// column : return the width of the line being printed
+func (p *printer) column() int {
+	b := p.out.Bytes()
+	start := bytes.LastIndexByte(b, '\n') + 1
+
+	width := 0
+	for _, ch := range b[start:] {
+		if ch == '\t' {
+			width += tabWidth
+		} else {
+			width++
+		}
+	}
+	return width
+}
+
+// blankLineIfGap : keep one blank line where the source had one or more
+// before line
+func (p *printer) blankLineIfGap(line int) {
+	if p.lastLine > 0 && line > p.lastLine+1 && !p.atBlockStart {
+		p.write("\n")
+	}
+}
+
+// flushComments : print the pending comments that start before line
+func (p *printer) flushComments(before int, level int) {
+	for len(p.comments) > 0 && p.comments[0].Line < before {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+
+		if c.Trailing && c.Line <= p.lastLine && p.out.Len() > 0 {
+			p.out.Truncate(p.out.Len() - 1) // the newline
+			p.write(" " + c.Text + "\n")
+			continue
+		}
+
+		p.blankLineIfGap(c.Line)
+		p.indent(level)
+		p.write(c.Text + "\n")
+		p.lastLine = c.Line
+		p.atBlockStart = false
+	}
+}
+
+func (p *printer) hasCommentBefore(line int) bool {
+	return len(p.comments) > 0 && p.comments[0].Line < line
+}
+
+func (p *printer) statements(stmts []ast.Statement, level int, end int) {
+	for _, s := range stmts {
+		start, last := lines(s)
+
+		p.flushComments(start, level)
+		p.blankLineIfGap(start)
+		p.statement(s, level)
+
+		if last > p.lastLine {
+			p.lastLine = last
+		}
+		p.atBlockStart = false
+	}
+
+	p.flushComments(end, level)
+}
+
+func (p *printer) statement(s ast.Statement, level int) {
+	p.indent(level)
+
+	switch s := s.(type) {
+	case *ast.LetStatement:
+		p.write("let " + s.Name.Value + " = ")
+		p.expression(s.Value, level)
+	case *ast.ReturnStatement:
+		p.write("return ")
+		p.expression(s.ReturnValue, level)
+	case *ast.ExpressionStatement:
+		p.expression(s.Expression, level)
+	}
+
+	// always terminate, so that a following statement starting with ( or -
+	// cannot be read as continuing a block-ending expression
+	p.write(";\n")
+}
+
+func (p *printer) block(b *ast.BlockStatement, level int) {
+	if len(b.Statements) == 0 && !p.hasCommentBefore(b.EndToken.Line) {
+		p.write("{}")
+		return
+	}
+
+	p.write("{\n")
+	p.lastLine = b.Token.Line
+	p.atBlockStart = true
+
+	p.statements(b.Statements, level+1, b.EndToken.Line)
+
+	p.indent(level)
+	p.write("}")
+	if b.EndToken.Line > p.lastLine {
+		p.lastLine = b.EndToken.Line
+	}
+}
+
+func (p *printer) expression(e ast.Expression, level int) {
+	switch e := e.(type) {
+	case *ast.Identifier:
+		p.write(e.Value)
+
+	case *ast.IntegerLiteral:
+		p.write(e.Token.Literal)
+
+	case *ast.Boolean:
+		p.write(e.Token.Literal)
+
+	case *ast.PrefixExpression:
+		p.write(e.Operator)
+		p.operand(e.Right, parser.PREFIX, false, level)
+
+	case *ast.InfixExpression:
+		precedence := operatorPrecedence(e.Operator)
+		p.operand(e.Left, precedence, false, level)
+		p.write(" " + e.Operator + " ")
+		p.operand(e.Right, precedence, true, level)
+
+	case *ast.IfExpression:
+		p.write("if (")
+		p.expression(e.Condition, level)
+		p.write(") ")
+		p.block(e.Consequence, level)
+		if e.Alternative != nil {
+			p.write(" else ")
+			p.block(e.Alternative, level)
+		}
+
+	case *ast.WhileExpression:
+		p.write("while (")
+		p.expression(e.Condition, level)
+		p.write(") ")
+		p.block(e.Consequence, level)
+
+	case *ast.FunctionLiteral:
+		params := []string{}
+		for _, param := range e.Parameters {
+			params = append(params, param.Value)
+		}
+		p.write("fn(" + strings.Join(params, ", ") + ") ")
+		p.block(e.Body, level)
+
+	case *ast.AttributeExpression:
+		p.operand(e.Object, parser.CALL, false, level)
+		p.write("." + e.Name)
+
+	case *ast.AssignExpression:
+		p.expression(e.Target, level)
+		p.write(" = ")
+		p.operand(e.Value, parser.ASSIGN, false, level)
+
+	case *ast.CallExpression:
+		p.operand(e.Function, parser.CALL, false, level)
+		p.arguments(e.Arguments, level)
+	}
+}
+
+// operand : print a sub-expression, parenthesized when its precedence is
+// lower than its parent's, or equal on the right of a left-associative
+// operator
+func (p *printer) operand(e ast.Expression, parent int, right bool, level int) {
+	precedence := expressionPrecedence(e)
+	if precedence < parent || (right && precedence == parent) {
+		p.write("(")
+		p.expression(e, level)
+		p.write(")")
+		return
+	}
+	p.expression(e, level)
+}
+
+// arguments : print call arguments, one per line if they do not fit
+func (p *printer) arguments(args []ast.Expression, level int) {
+	flat := &printer{}
+	for i, a := range args {
+		if i > 0 {
+			flat.write(", ")
+		}
+		flat.expression(a, 0)
+	}
+	oneLine := flat.out.String()
+
+	if len(args) > 1 && !strings.Contains(oneLine, "\n") &&
+		p.column()+len(oneLine)+2 > MaxWidth {
+		p.write("(\n")
+		for i, a := range args {
+			p.indent(level + 1)
+			p.expression(a, level+1)
+			if i < len(args)-1 {
+				p.write(",")
+			}
+			p.write("\n")
+		}
+		p.indent(level)
+		p.write(")")
+		return
+	}
+
+	p.write("(")
+	for i, a := range args {
+		if i > 0 {
+			p.write(", ")
+		}
+		p.expression(a, level)
+	}
+	p.write(")")
+}
+
+func operatorPrecedence(operator string) int {
+	switch operator {
+	case "==", "!=":
+		return parser.EQUALS
+	case "<", ">", "<=", ">=":
+		return parser.LESSGREATER
+	case "+", "-":
+		return parser.SUM
+	case "*", "/":
+		return parser.PRODUCT
+	}
+	return parser.LOWEST
+}
+
+// expressionPrecedence : return how tightly an expression binds; literals,
+// identifiers and expressions ending in a block never need parentheses
+func expressionPrecedence(e ast.Expression) int {
+	switch e := e.(type) {
+	case *ast.InfixExpression:
+		return operatorPrecedence(e.Operator)
+	case *ast.PrefixExpression:
+		return parser.PREFIX
+	case *ast.AssignExpression:
+		return parser.ASSIGN
+	case *ast.CallExpression, *ast.AttributeExpression:
+		return parser.CALL
+	}
+	return parser.CALL + 1
+}
+
+// lines : return the first and last source line of a node as far as its
+// tokens tell
+func lines(node ast.Node) (int, int) {
+	first, last := 0, 0
+	see := func(line int) {
+		if line == 0 {
+			return
+		}
+		if first == 0 || line < first {
+			first = line
+		}
+		if line > last {
+			last = line
+		}
+	}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.LetStatement:
+			see(n.Token.Line)
+		case *ast.ReturnStatement:
+			see(n.Token.Line)
+		case *ast.ExpressionStatement:
+			see(n.Token.Line)
+		case *ast.BlockStatement:
+			see(n.Token.Line)
+			see(n.EndToken.Line)
+		case *ast.Identifier:
+			see(n.Token.Line)
+		case *ast.IntegerLiteral:
+			see(n.Token.Line)
+		case *ast.Boolean:
+			see(n.Token.Line)
+		case *ast.PrefixExpression:
+			see(n.Token.Line)
+		case *ast.InfixExpression:
+			see(n.Token.Line)
+		case *ast.IfExpression:
+			see(n.Token.Line)
+		case *ast.WhileExpression:
+			see(n.Token.Line)
+		case *ast.FunctionLiteral:
+			see(n.Token.Line)
+		case *ast.AttributeExpression:
+			see(n.Token.Line)
+		case *ast.AssignExpression:
+			see(n.Token.Line)
+		case *ast.CallExpression:
+			see(n.Token.Line)
+		}
+		return true
+	})
+
+	return first, last
+}
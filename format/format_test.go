@@ -0,0 +1,108 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestSource(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x=5", "let x = 5;\n"},
+		{"return   x+1 ;", "return x + 1;\n"},
+		{"(1 + 2) * 3; 1 + (2 * 3); 1 - (2 - 3); (1 - 2) - 3; -(-a); -(a + b);",
+			"(1 + 2) * 3;\n1 + 2 * 3;\n1 - (2 - 3);\n1 - 2 - 3;\n--a;\n-(a + b);\n"},
+		{"(a + b)(1); f(1)(2); -f(x)", "(a + b)(1);\nf(1)(2);\n-f(x);\n"},
+		{"let add = fn(a,b){a+b};", "let add = fn(a, b) {\n\ta + b;\n};\n"},
+		{"fn(){}", "fn() {};\n"},
+		{"if(x<1){1}else{if(y){2}}",
+			"if (x < 1) {\n\t1;\n} else {\n\tif (y) {\n\t\t2;\n\t};\n};\n"},
+		{"while(x>0){let x=x-1;}", "while (x > 0) {\n\tlet x = x - 1;\n};\n"},
+		{"let a = 1;\n\n\n\nlet b = 2;\nlet c = 3;",
+			"let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		{"if (x) {\n\n1\n\n}", "if (x) {\n\t1;\n};\n"},
+		{"req . header( name ).length; (a+b).c; (-a).b; -a.b", "req.header(name).length;\n(a + b).c;\n(-a).b;\n-a.b;\n"},
+		{"a.b=c.d=1; (a.b=1)+2", "a.b = c.d = 1;\n(a.b = 1) + 2;\n"},
+		{"apply(aaaaaaaaaaaaaaaaaaaa, bbbbbbbbbbbbbbbbbbbb, cccccccccccccccccccc, dddddddddd)",
+			"apply(\n\taaaaaaaaaaaaaaaaaaaa,\n\tbbbbbbbbbbbbbbbbbbbb,\n\tcccccccccccccccccccc,\n\tdddddddddd\n);\n"},
+	}
+
+	for _, tt := range tests {
+		formatted, err := Source(tt.input)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", tt.input, err)
+			continue
+		}
+		if formatted != tt.expected {
+			t.Errorf("Source(%q) wrong.\nexpected=%q\ngot=%q", tt.input, tt.expected, formatted)
+		}
+	}
+}
+
+func TestSourceComments(t *testing.T) {
+	input := `// header
+
+let add = fn(a, b) { // adds
+	// the sum
+	a + b
+};   // end of add
+
+if (x) {
+	// nothing yet
+}
+// footer`
+
+	expected := `// header
+
+let add = fn(a, b) { // adds
+	// the sum
+	a + b;
+}; // end of add
+
+if (x) {
+	// nothing yet
+};
+// footer
+`
+
+	formatted, err := Source(input)
+	if err != nil {
+		t.Fatalf("Source returned error: %s", err)
+	}
+	if formatted != expected {
+		t.Errorf("comments not preserved.\nexpected=%q\ngot=%q", expected, formatted)
+	}
+}
+
+func TestSourceIdempotent(t *testing.T) {
+	inputs := []string{
+		"let x = (1+2)*3 - (4-5); // x\n\n\nx",
+		"let f = fn(n) { if (n < 2) { return n } // base\n f(n-1) + f(n-2) };\nf(10)",
+		"// a\n// b\nwhile (i < 10) { let i = i + 1; // step\n\n// inside\n}\n\n// c",
+		"apply(fn(x) { x * 2 }, longargumentnumberone, longargumentnumbertwo, longargumentnumberthree)",
+		"if (a) {} else { b } - 1",
+	}
+
+	for _, input := range inputs {
+		once, err := Source(input)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", input, err)
+			continue
+		}
+		twice, err := Source(once)
+		if err != nil {
+			t.Errorf("Source(%q) returned error: %s", once, err)
+			continue
+		}
+		if once != twice {
+			t.Errorf("formatting %q is not idempotent.\nonce=%q\ntwice=%q", input, once, twice)
+		}
+	}
+}
+
+func TestSourceParseError(t *testing.T) {
+	if _, err := Source("let = 5;"); err == nil {
+		t.Errorf("expected a parse error")
+	}
+}
@@ -0,0 +1,125 @@
+package highlight
+
+import (
+	"html"
+	"strings"
+
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+// Class : the highlighting class of a piece of source
+type Class string
+
+// Classes of source text
+const (
+	Plain      Class = ""
+	Keyword    Class = "keyword"
+	Number     Class = "number"
+	Operator   Class = "operator"
+	Identifier Class = "identifier"
+	Comment    Class = "comment"
+	Error      Class = "error"
+)
+
+// Segment : a run of source text of one class
+type Segment struct {
+	Class Class
+	Text  string
+}
+
+// ansiColors : terminal colors of each class; plain text and identifiers
+// keep the terminal's color
+var ansiColors = map[Class]string{
+	Keyword:  "\x1b[35m",
+	Number:   "\x1b[36m",
+	Operator: "\x1b[33m",
+	Comment:  "\x1b[90m",
+	Error:    "\x1b[4;31m",
+}
+
+const ansiReset = "\x1b[0m"
+
+// ClassOf : return the highlighting class of a token
+func ClassOf(tok token.Token) Class {
+	switch tok.Type {
+	case token.IDENT:
+		return Identifier
+	case token.INT:
+		return Number
+	case token.ILLEGAL:
+		return Error
+	case token.EOF, token.COMMA, token.SEMICOLON, token.DOT,
+		token.LPAREN, token.RPAREN, token.LBRACE, token.RBRACE:
+		return Plain
+	}
+	if token.LookupIdent(tok.Literal) != token.IDENT {
+		return Keyword
+	}
+	return Operator
+}
+
+// Segments : split src into classified runs that concatenate back to src.
+// Adjacent runs of one class are merged, which keeps the bytes of a
+// multi-byte character lexed as separate illegal tokens together.
+func Segments(src string) []Segment {
+	var segments []Segment
+	add := func(class Class, text string) {
+		if text == "" {
+			return
+		}
+		if n := len(segments); n > 0 && segments[n-1].Class == class {
+			segments[n-1].Text += text
+			return
+		}
+		segments = append(segments, Segment{Class: class, Text: text})
+	}
+
+	l := lexer.NewWithTrivia(src)
+	for {
+		tok := l.NextToken()
+		for _, trivia := range tok.Leading {
+			if trivia.Kind == token.COMMENT {
+				add(Comment, trivia.Text)
+			} else {
+				add(Plain, trivia.Text)
+			}
+		}
+		add(ClassOf(tok), tok.Literal)
+
+		if tok.Type == token.EOF {
+			return segments
+		}
+	}
+}
+
+// ANSI : return src colored for a terminal
+func ANSI(src string) string {
+	var out strings.Builder
+	for _, s := range Segments(src) {
+		color, ok := ansiColors[s.Class]
+		if !ok {
+			out.WriteString(s.Text)
+			continue
+		}
+		out.WriteString(color + s.Text + ansiReset)
+	}
+	return out.String()
+}
+
+// HTML : return src as a <pre> element with a span of class mk-<class>
+// around each classified run
+func HTML(src string) string {
+	var out strings.Builder
+	out.WriteString(`<pre class="monkey">`)
+	for _, s := range Segments(src) {
+		text := html.EscapeString(s.Text)
+		if s.Class == Plain {
+			out.WriteString(text)
+			continue
+		}
+		out.WriteString(`<span class="mk-` + string(s.Class) + `">` + text + `</span>`)
+	}
+	out.WriteString("</pre>\n")
+	return out.String()
+}
@@ -0,0 +1,54 @@
+package highlight
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSegments(t *testing.T) {
+	input := "let x = fn(a) { a <= 10 }; // note\nif (true) { x(1) } @é"
+
+	expected := []Segment{
+		{Keyword, "let"}, {Plain, " "}, {Identifier, "x"}, {Plain, " "},
+		{Operator, "="}, {Plain, " "}, {Keyword, "fn"}, {Plain, "("},
+		{Identifier, "a"}, {Plain, ") { "}, {Identifier, "a"}, {Plain, " "},
+		{Operator, "<="}, {Plain, " "}, {Number, "10"}, {Plain, " }; "},
+		{Comment, "// note"}, {Plain, "\n"}, {Keyword, "if"}, {Plain, " ("},
+		{Keyword, "true"}, {Plain, ") { "}, {Identifier, "x"}, {Plain, "("},
+		{Number, "1"}, {Plain, ") } "}, {Error, "@é"},
+	}
+
+	segments := Segments(input)
+	if len(segments) != len(expected) {
+		t.Fatalf("wrong number of segments. expected=%d, got=%d (%q)",
+			len(expected), len(segments), segments)
+	}
+	for i, s := range expected {
+		if segments[i] != s {
+			t.Errorf("segments[%d] wrong. expected=%q, got=%q", i, s, segments[i])
+		}
+	}
+
+	var joined strings.Builder
+	for _, s := range segments {
+		joined.WriteString(s.Text)
+	}
+	if joined.String() != input {
+		t.Errorf("segments do not spell out the input. got=%q", joined.String())
+	}
+}
+
+func TestANSI(t *testing.T) {
+	expected := "\x1b[35mlet\x1b[0m a \x1b[33m=\x1b[0m \x1b[36m1\x1b[0m; \x1b[90m// c\x1b[0m"
+	if got := ANSI("let a = 1; // c"); got != expected {
+		t.Errorf("ANSI wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
+
+func TestHTML(t *testing.T) {
+	expected := `<pre class="monkey"><span class="mk-keyword">if</span> (<span class="mk-identifier">a</span> ` +
+		`<span class="mk-operator">&lt;</span> <span class="mk-number">2</span>) {}</pre>` + "\n"
+	if got := HTML("if (a < 2) {}"); got != expected {
+		t.Errorf("HTML wrong.\nexpected=%q\ngot=%q", expected, got)
+	}
+}
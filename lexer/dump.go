@@ -0,0 +1,55 @@
+package lexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+// Tokens : return all tokens of input, ending with the EOF token
+func Tokens(input string) []token.Token {
+	l := New(input)
+
+	var tokens []token.Token
+	for {
+		tok := l.NextToken()
+		tokens = append(tokens, tok)
+		if tok.Type == token.EOF {
+			return tokens
+		}
+	}
+}
+
+// jsonToken : the JSON form of a token written by Dump
+type jsonToken struct {
+	Type    token.TokenType `json:"type"`
+	Literal string          `json:"literal"`
+	Line    int             `json:"line"`
+	Column  int             `json:"column"`
+}
+
+// Dump : write the tokens of input one per line with their position, type
+// and quoted literal, or as a JSON array when asJSON is set
+func Dump(w io.Writer, input string, asJSON bool) error {
+	tokens := Tokens(input)
+
+	if asJSON {
+		out := []jsonToken{}
+		for _, tok := range tokens {
+			out = append(out, jsonToken{Type: tok.Type, Literal: tok.Literal, Line: tok.Line, Column: tok.Column})
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(out)
+	}
+
+	for _, tok := range tokens {
+		position := fmt.Sprintf("%d:%d", tok.Line, tok.Column)
+		if _, err := fmt.Fprintf(w, "%-7s %-9s %q\n", position, tok.Type, tok.Literal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
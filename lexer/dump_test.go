@@ -0,0 +1,54 @@
+package lexer
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDump(t *testing.T) {
+	input := "let x = 1;\nx @"
+
+	expected := `1:1     LET       "let"
+1:5     IDENT     "x"
+1:7     =         "="
+1:9     INT       "1"
+1:10    ;         ";"
+2:1     IDENT     "x"
+2:3     ILLEGAL   "@"
+2:4     EOF       ""
+`
+
+	var out bytes.Buffer
+	if err := Dump(&out, input, false); err != nil {
+		t.Fatalf("Dump returned error: %s", err)
+	}
+	if out.String() != expected {
+		t.Errorf("Dump wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestDumpJSON(t *testing.T) {
+	expected := `[
+  {
+    "type": "INT",
+    "literal": "5",
+    "line": 1,
+    "column": 1
+  },
+  {
+    "type": "EOF",
+    "literal": "",
+    "line": 1,
+    "column": 2
+  }
+]
+`
+
+	var out bytes.Buffer
+	if err := Dump(&out, "5", true); err != nil {
+		t.Fatalf("Dump returned error: %s", err)
+	}
+	if out.String() != expected {
+		t.Errorf("Dump wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
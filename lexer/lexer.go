@@ -1,6 +1,10 @@
 package lexer
 
-import "github.com/rockspore/monkey-interpreter/token"
+import (
+	"strings"
+
+	"github.com/rockspore/monkey-interpreter/token"
+)
 
 // Lexer : definition of the lexer struct
 type Lexer struct {
@@ -8,20 +12,47 @@ type Lexer struct {
 	position     int
 	readPosition int
 	ch           byte
+	line         int // line of ch, starting at 1
+	lineStart    int // position of the first character of line
+
+	lastTokenLine int // line of the last token returned by NextToken
+	comments      []Comment
+
+	keepTrivia bool
+	trivia     []token.Trivia // skipped since the last token
+}
+
+// Comment : a `//` comment skipped by the lexer
+type Comment struct {
+	Text     string // including the leading //
+	Line     int
+	Trailing bool // follows a token on the same line
 }
 
 // New : generates a new lexer based on the input string
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	l := &Lexer{input: input, line: 1}
 	l.readChar()
 	return l
 }
 
+// NewWithTrivia : generates a new lexer that attaches the whitespace and
+// comments before each token to it, so the tokens spell out the input exactly
+func NewWithTrivia(input string) *Lexer {
+	l := New(input)
+	l.keepTrivia = true
+	return l
+}
+
 // NextToken : primary method to get next token
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
 	l.skipWhitespace()
+	line := l.line
+	column := l.position - l.lineStart + 1
+	leading := l.trivia
+	l.trivia = nil
 
 	switch l.ch {
 	case '=':
@@ -72,6 +103,8 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
 		tok = newToken(token.COMMA, l.ch)
+	case '.':
+		tok = newToken(token.DOT, l.ch)
 	case '{':
 		tok = newToken(token.LBRACE, l.ch)
 	case '}':
@@ -87,27 +120,83 @@ func (l *Lexer) NextToken() token.Token {
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
+			tok.Line = line
+			tok.Column = column
+			tok.Leading = leading
+			l.lastTokenLine = line
 			return tok
 		} else if isDigit(l.ch) {
 			tok.Type = token.INT
 			tok.Literal = l.readNumber()
+			tok.Line = line
+			tok.Column = column
+			tok.Leading = leading
+			l.lastTokenLine = line
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch)
+			// sliced rather than converted, so that bytes of multi-byte
+			// characters stay intact
+			tok = token.Token{Type: token.ILLEGAL, Literal: l.input[l.position:l.readPosition]}
 		}
 	}
 
 	l.readChar()
+	tok.Line = line
+	tok.Column = column
+	tok.Leading = leading
+	l.lastTokenLine = line
 	return tok
 }
 
+// Comments : return the comments skipped so far, in source order
+func (l *Lexer) Comments() []Comment {
+	return l.comments
+}
+
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case isWhitespace(l.ch):
+			position := l.position
+			for isWhitespace(l.ch) {
+				l.readChar()
+			}
+			l.addTrivia(token.WHITESPACE, position)
+		case l.ch == '/' && l.peekChar() == '/':
+			position := l.position
+			l.skipComment()
+			l.addTrivia(token.COMMENT, position)
+		default:
+			return
+		}
+	}
+}
+
+func (l *Lexer) skipComment() {
+	position := l.position
+	for l.ch != '\n' && l.ch != 0 {
 		l.readChar()
 	}
+
+	l.comments = append(l.comments, Comment{
+		Text:     strings.TrimRight(l.input[position:l.position], " \t\r"),
+		Line:     l.line,
+		Trailing: l.lastTokenLine == l.line,
+	})
+}
+
+// addTrivia : keep the input from position up to the current character
+func (l *Lexer) addTrivia(kind token.TriviaKind, position int) {
+	if l.keepTrivia {
+		l.trivia = append(l.trivia, token.Trivia{Kind: kind, Text: l.input[position:l.position]})
+	}
 }
 
 func (l *Lexer) readChar() {
+	if l.ch == '\n' {
+		l.line++
+		l.lineStart = l.readPosition
+	}
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
@@ -144,6 +233,10 @@ func isLetter(ch byte) bool {
 	return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
 }
 
+func isWhitespace(ch byte) bool {
+	return ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r'
+}
+
 func isDigit(ch byte) bool {
 	return '0' <= ch && ch <= '9'
 }
@@ -26,6 +26,8 @@ if (5 < 10) {
 
 10 == 10;
 10 != 9;
+x1 = 2x;
+req.ok;
 `
 
 	tests := []struct {
@@ -105,6 +107,16 @@ if (5 < 10) {
 		{token.NEQ, "!="},
 		{token.INT, "9"},
 		{token.SEMICOLON, ";"},
+		{token.IDENT, "x"},
+		{token.INT, "1"},
+		{token.ASSIGN, "="},
+		{token.INT, "2"},
+		{token.IDENT, "x"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "req"},
+		{token.DOT, "."},
+		{token.IDENT, "ok"},
+		{token.SEMICOLON, ";"},
 		{token.EOF, ""},
 	}
 
@@ -124,3 +136,151 @@ if (5 < 10) {
 		}
 	}
 }
+
+func TestNextTokenPosition(t *testing.T) {
+	input := `let five = 5;
+
+let add = fn(x, y) {
+  x + y;
+};
+`
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int
+	}{
+		{"let", 1, 1},
+		{"five", 1, 5},
+		{"=", 1, 10},
+		{"5", 1, 12},
+		{";", 1, 13},
+		{"let", 3, 1},
+		{"add", 3, 5},
+		{"=", 3, 9},
+		{"fn", 3, 11},
+		{"(", 3, 13},
+		{"x", 3, 14},
+		{",", 3, 15},
+		{"y", 3, 17},
+		{")", 3, 18},
+		{"{", 3, 20},
+		{"x", 4, 3},
+		{"+", 4, 5},
+		{"y", 4, 7},
+		{";", 4, 8},
+		{"}", 5, 1},
+		{";", 5, 2},
+		{"", 6, 1},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if tok.Line != tt.expectedLine {
+			t.Fatalf("tests[%d] - line wrong. expected=%d, got=%d",
+				i, tt.expectedLine, tok.Line)
+		}
+
+		if tok.Column != tt.expectedColumn {
+			t.Fatalf("tests[%d] - column wrong. expected=%d, got=%d",
+				i, tt.expectedColumn, tok.Column)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// leading
+let a = 1; // trailing
+a / 2 //no space
+
+// last`
+
+	expectedTokens := []string{"let", "a", "=", "1", ";", "a", "/", "2", ""}
+	expectedComments := []Comment{
+		{Text: "// leading", Line: 1, Trailing: false},
+		{Text: "// trailing", Line: 2, Trailing: true},
+		{Text: "//no space", Line: 3, Trailing: true},
+		{Text: "// last", Line: 5, Trailing: false},
+	}
+
+	l := New(input)
+
+	for i, literal := range expectedTokens {
+		tok := l.NextToken()
+		if tok.Literal != literal {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, literal, tok.Literal)
+		}
+	}
+
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("wrong number of comments. expected=%d, got=%d",
+			len(expectedComments), len(comments))
+	}
+	for i, c := range expectedComments {
+		if comments[i] != c {
+			t.Errorf("comments[%d] wrong. expected=%+v, got=%+v", i, c, comments[i])
+		}
+	}
+}
+
+func TestNextTokenTrivia(t *testing.T) {
+	input := "let a = 1; // one\n\n  a\t@é"
+
+	tests := []struct {
+		expectedLiteral string
+		expectedLeading []token.Trivia
+	}{
+		{"let", nil},
+		{"a", []token.Trivia{{Kind: token.WHITESPACE, Text: " "}}},
+		{"=", []token.Trivia{{Kind: token.WHITESPACE, Text: " "}}},
+		{"1", []token.Trivia{{Kind: token.WHITESPACE, Text: " "}}},
+		{";", nil},
+		{"a", []token.Trivia{
+			{Kind: token.WHITESPACE, Text: " "},
+			{Kind: token.COMMENT, Text: "// one"},
+			{Kind: token.WHITESPACE, Text: "\n\n  "},
+		}},
+		{"@", []token.Trivia{{Kind: token.WHITESPACE, Text: "\t"}}},
+		{"\xc3", nil},
+		{"\xa9", nil},
+		{"", nil},
+	}
+
+	l := NewWithTrivia(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - literal wrong. expected=%q, got=%q",
+				i, tt.expectedLiteral, tok.Literal)
+		}
+
+		if len(tok.Leading) != len(tt.expectedLeading) {
+			t.Fatalf("tests[%d] - wrong number of trivia. expected=%d, got=%d",
+				i, len(tt.expectedLeading), len(tok.Leading))
+		}
+		for j, trivia := range tt.expectedLeading {
+			if tok.Leading[j] != trivia {
+				t.Fatalf("tests[%d] - trivia[%d] wrong. expected=%+v, got=%+v",
+					i, j, trivia, tok.Leading[j])
+			}
+		}
+	}
+
+	plain := New(input)
+	plain.NextToken()
+	if tok := plain.NextToken(); len(tok.Leading) != 0 {
+		t.Fatalf("plain lexer kept trivia. got=%+v", tok.Leading)
+	}
+}
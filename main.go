@@ -1,20 +1,551 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/compiler"
+	"github.com/rockspore/monkey-interpreter/engine"
+	"github.com/rockspore/monkey-interpreter/format"
+	"github.com/rockspore/monkey-interpreter/highlight"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/object"
+	"github.com/rockspore/monkey-interpreter/optimizer"
+	"github.com/rockspore/monkey-interpreter/parser"
 	"github.com/rockspore/monkey-interpreter/repl"
+	"github.com/rockspore/monkey-interpreter/vm"
 )
 
+var (
+	engineName = flag.String("engine", "eval", engineUsage)
+	optimize   = flag.Bool("O", true, optimizeUsage)
+)
+
+var (
+	engineUsage   = "execution engine to use (" + strings.Join(engine.Names(), ", ") + ")"
+	optimizeUsage = "optimize programs before running them"
+)
+
+const usage = `Usage:
+  monkey [flags] [repl]                start the REPL
+  monkey [flags] run [flags] [file.mk [args...]]
+                                       run a source file (or stdin), with
+                                       integer args read with arg(1)... and
+                                       their count in argc
+  monkey [flags] run file.mkc          run a compiled file
+  monkey [flags] eval [-e 'source']    evaluate source (or stdin)
+  monkey build [-o out.mkc] file.mk    compile a source file to bytecode
+  monkey disasm file.mk|file.mkc       print the bytecode of a file
+  monkey fmt [-w] [file.mk...]         format source files (or stdin)
+  monkey ast [--json] file.mk          print the syntax tree of a file
+  monkey tokens [--json] file.mk       print the tokens of a file
+  monkey parse [--trace] file.mk       parse a file, tracing the parser
+  monkey highlight [--format=ansi|html] file.mk
+                                       print a file with syntax highlighting
+
+Flags:
+`
+
 func main() {
-	user, err := user.Current()
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := runCommand(flag.Arg(0), flag.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// runCommand : run the named command, turning a panic into an error so that
+// a crash exits like any other failure
+func runCommand(command string, args []string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("internal error: %v", r)
+		}
+	}()
+
+	if len(args) > 0 {
+		args = args[1:]
+	}
+	switch command {
+	case "", "repl":
+		return startRepl()
+	case "run":
+		return runFile(args)
+	case "eval":
+		return evalSource(args)
+	case "build":
+		return buildFile(args)
+	case "disasm":
+		return disasmFile(args)
+	case "fmt":
+		return formatFiles(args)
+	case "ast":
+		return printAST(args)
+	case "tokens":
+		return printTokens(args)
+	case "parse":
+		return parseOnly(args)
+	case "highlight":
+		return highlightFile(args)
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
+
+// newEngine : create the engine selected with the -engine and -O flags
+func newEngine() (engine.Engine, error) {
+	if *optimize {
+		return engine.New(*engineName, optimizer.DefaultPasses...)
+	}
+	return engine.New(*engineName)
+}
+
+func startRepl() error {
+	eng, err := newEngine()
+	if err != nil {
+		return err
+	}
+
+	if repl.IsTerminal(os.Stdin) {
+		user, err := user.Current()
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Hello %s! This is the Monkey programming language!\n",
+			user.Username)
+		fmt.Printf("Feel free to type in commands\n")
+	}
+	color := os.Getenv("NO_COLOR") == "" && repl.IsTerminal(os.Stdout)
+	opts := []repl.Option{repl.WithErrors(os.Stderr), repl.WithColor(color)}
+	if home, err := os.UserHomeDir(); err == nil {
+		opts = append(opts, repl.WithHistory(filepath.Join(home, ".monkey_history")))
+	}
+	repl.Start(os.Stdin, os.Stdout, eng, opts...)
+	return nil
+}
+
+// runFile : run a source file, or stdin when the path is - or missing, on
+// the selected engine, or a compiled .mkc file on the vm, printing the value of
+// its last statement. Arguments after the file are integers returned by the
+// builtin arg, arg(1) being the first, with their count in argc.
+func runFile(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ExitOnError)
+	flags.StringVar(engineName, "engine", *engineName, engineUsage)
+	flags.BoolVar(optimize, "O", *optimize, optimizeUsage)
+	flags.Parse(args)
+
+	args = flags.Args()
+	if len(args) == 0 {
+		args = []string{"-"}
+	}
+	path, params := args[0], args[1:]
+
+	var result object.Object
+	var err error
+	if strings.HasSuffix(path, ".mkc") {
+		if len(params) != 0 {
+			return fmt.Errorf("%s: compiled files take no arguments", path)
+		}
+		result, err = runCompiled(path)
+	} else {
+		result, err = runSource(path, params)
+	}
+	if err != nil {
+		return err
+	}
+
+	printResult(result)
+	return nil
+}
+
+// evalSource : evaluate the source given with -e, or read from stdin
+func evalSource(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	expr := flags.String("e", "", "source to evaluate")
+	flags.Parse(args)
+
+	if flags.NArg() != 0 {
+		return fmt.Errorf("usage: monkey eval [-e 'source']")
+	}
+
+	name, src := "-e", *expr
+	if *expr == "" {
+		var err error
+		if name, src, err = readSource("-"); err != nil {
+			return err
+		}
+	}
+
+	eng, err := newEngine()
 	if err != nil {
-		panic(err)
+		return err
+	}
+	result, err := runProgram(eng, name, src)
+	if err != nil {
+		return err
+	}
+
+	printResult(result)
+	return nil
+}
+
+func runSource(path string, params []string) (object.Object, error) {
+	eng, err := newEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bindArguments(eng, params); err != nil {
+		return nil, err
+	}
+
+	name, src, err := readSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return runProgram(eng, name, src)
+}
+
+// runProgram : parse and run src on the engine, naming it in diagnostics
+func runProgram(eng engine.Engine, name string, src string) (object.Object, error) {
+	program, err := parseSource(name, src)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := eng.Run(program)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", name, err)
+	}
+	return result, nil
+}
+
+// bindArguments : bind script arguments to the builtin arg and their count
+// to argc
+func bindArguments(eng engine.Engine, params []string) error {
+	values := make([]object.Object, len(params))
+	for i, param := range params {
+		value, err := strconv.ParseInt(param, 0, 64)
+		if err != nil {
+			return fmt.Errorf("argument %q is not an integer", param)
+		}
+		values[i] = &object.Integer{Value: value}
+	}
+
+	eng.Set("arg", &object.Builtin{Name: "arg", Fn: func(args ...object.Object) object.Object {
+		if len(args) != 1 {
+			return &object.Error{Message: fmt.Sprintf("wrong number of arguments: want=1, got=%d", len(args))}
+		}
+		n, ok := args[0].(*object.Integer)
+		if !ok || n.Value < 1 || n.Value > int64(len(values)) {
+			return &object.Error{Message: fmt.Sprintf("no argument %s", args[0].Inspect())}
+		}
+		return values[n.Value-1]
+	}})
+	eng.Set("argc", &object.Integer{Value: int64(len(values))})
+	return nil
+}
+
+func printResult(result object.Object) {
+	if result != nil && result.Type() != object.NullOBJ {
+		fmt.Println(result.Inspect())
+	}
+}
+
+func runCompiled(path string) (object.Object, error) {
+	bytecode, err := readCompiled(path)
+	if err != nil {
+		return nil, err
+	}
+
+	machine := vm.New(bytecode)
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+// buildFile : compile a source file into a .mkc file next to it, or to the
+// path given with -o
+func buildFile(args []string) error {
+	flags := flag.NewFlagSet("build", flag.ExitOnError)
+	output := flags.String("o", "", "output file (default: source file with .mkc extension)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey build [-o out.mkc] file.mk")
+	}
+	path := flags.Arg(0)
+
+	program, err := parseFile(path)
+	if err != nil {
+		return err
+	}
+
+	if *optimize {
+		program = optimizer.Optimize(program, optimizer.DefaultPasses...)
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	if *output == "" {
+		*output = strings.TrimSuffix(path, ".mk") + ".mkc"
+	}
+
+	f, err := os.Create(*output)
+	if err != nil {
+		return err
+	}
+	if err := compiler.WriteBytecode(f, comp.Bytecode()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// disasmFile : print the disassembled bytecode of a source or compiled file
+func disasmFile(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: monkey disasm file.mk|file.mkc")
+	}
+	path := args[0]
+
+	var bytecode *compiler.Bytecode
+	if strings.HasSuffix(path, ".mkc") {
+		var err error
+		if bytecode, err = readCompiled(path); err != nil {
+			return err
+		}
+	} else {
+		program, err := parseFile(path)
+		if err != nil {
+			return err
+		}
+		if *optimize {
+			program = optimizer.Optimize(program, optimizer.DefaultPasses...)
+		}
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		bytecode = comp.Bytecode()
+	}
+
+	fmt.Print(compiler.Disassemble(bytecode))
+	return nil
+}
+
+// formatFiles : print the formatted source of each file, or rewrite the
+// files in place with -w; without files, format stdin
+func formatFiles(args []string) error {
+	flags := flag.NewFlagSet("fmt", flag.ExitOnError)
+	write := flags.Bool("w", false, "write the result to the source file instead of stdout")
+	flags.Parse(args)
+
+	if flags.NArg() == 0 {
+		if *write {
+			return fmt.Errorf("usage: monkey fmt -w file.mk...")
+		}
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return err
+		}
+		formatted, err := format.Source(string(src))
+		if err != nil {
+			return fmt.Errorf("<stdin>: %s", err)
+		}
+		fmt.Print(formatted)
+		return nil
+	}
+
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		formatted, err := format.Source(string(src))
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+
+		if !*write {
+			fmt.Print(formatted)
+			continue
+		}
+		if formatted == string(src) {
+			continue
+		}
+		if err := os.WriteFile(path, []byte(formatted), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// printAST : print the parsed program of a file, fully parenthesized or as
+// JSON with every node's kind, token and children
+func printAST(args []string) error {
+	flags := flag.NewFlagSet("ast", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tree as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey ast [--json] file.mk")
+	}
+
+	program, err := parseFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	if !*asJSON {
+		for _, stmt := range program.Statements {
+			fmt.Println(stmt.String())
+		}
+		return nil
+	}
+
+	data, err := ast.ToJSON(program)
+	if err != nil {
+		return err
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	fmt.Println(out.String())
+	return nil
+}
+
+// printTokens : print the tokens of a file with their positions
+func printTokens(args []string) error {
+	flags := flag.NewFlagSet("tokens", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the tokens as JSON")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey tokens [--json] file.mk")
+	}
+
+	src, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	return lexer.Dump(os.Stdout, string(src), *asJSON)
+}
+
+// parseOnly : parse a file and print its program, optionally tracing each
+// parsing function to stdout
+func parseOnly(args []string) error {
+	flags := flag.NewFlagSet("parse", flag.ExitOnError)
+	trace := flags.Bool("trace", false, "trace the parsing functions")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey parse [--trace] file.mk")
+	}
+
+	var opts []parser.Option
+	if *trace {
+		opts = append(opts, parser.WithTrace(os.Stdout))
+	}
+
+	program, err := parseFile(flags.Arg(0), opts...)
+	if err != nil {
+		return err
+	}
+	fmt.Println(program.String())
+	return nil
+}
+
+// highlightFile : print a file highlighted for a terminal or as HTML
+func highlightFile(args []string) error {
+	flags := flag.NewFlagSet("highlight", flag.ExitOnError)
+	format := flags.String("format", "ansi", "output format (ansi, html)")
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: monkey highlight [--format=ansi|html] file.mk")
+	}
+
+	_, src, err := readSource(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "ansi":
+		fmt.Print(highlight.ANSI(src))
+	case "html":
+		fmt.Print(highlight.HTML(src))
+	default:
+		return fmt.Errorf("unknown format %q, want ansi or html", *format)
+	}
+	return nil
+}
+
+func readCompiled(path string) (*compiler.Bytecode, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	bytecode, err := compiler.ReadBytecode(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return bytecode, nil
+}
+
+// readSource : read a source file, or stdin when path is -, returning the
+// name to use in diagnostics
+func readSource(path string) (string, string, error) {
+	if path == "-" {
+		src, err := io.ReadAll(os.Stdin)
+		return "<stdin>", string(src), err
+	}
+
+	src, err := os.ReadFile(path)
+	return path, string(src), err
+}
+
+// parseFile : read and parse a source file, or stdin when path is -,
+// reporting all parse errors
+func parseFile(path string, opts ...parser.Option) (*ast.Program, error) {
+	name, src, err := readSource(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(name, src, opts...)
+}
+
+func parseSource(name string, src string, opts ...parser.Option) (*ast.Program, error) {
+	p := parser.New(lexer.New(src), opts...)
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, fmt.Errorf("%s: parse errors:\n\t%s",
+			name, strings.Join(p.Errors(), "\n\t"))
 	}
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n",
-		user.Username)
-	fmt.Printf("Feel free to type in commands\n")
-	repl.Start(os.Stdin, os.Stdout)
+	return program, nil
 }
@@ -0,0 +1,284 @@
+package monkey
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+var (
+	objectType = reflect.TypeOf((*object.Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToObject : convert a Go value to a Monkey object. Integers, booleans,
+// strings, slices, arrays and maps convert to their Monkey counterparts, nil
+// to null, and objects are returned as they are.
+func ToObject(v interface{}) (object.Object, error) {
+	if v == nil {
+		return &object.Null{}, nil
+	}
+	return toObject(reflect.ValueOf(v))
+}
+
+func toObject(v reflect.Value) (object.Object, error) {
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return &object.Null{}, nil
+	}
+	if v.Type().Implements(objectType) {
+		return v.Interface().(object.Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return &object.Boolean{Value: v.Bool()}, nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &object.Integer{Value: v.Int()}, nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("cannot convert %d to INTEGER: out of range", v.Uint())
+		}
+		return &object.Integer{Value: int64(v.Uint())}, nil
+
+	case reflect.String:
+		return &object.String{Value: v.String()}, nil
+
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return &object.Null{}, nil
+		}
+		elements := make([]object.Object, v.Len())
+		for i := range elements {
+			element, err := toObject(v.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			elements[i] = element
+		}
+		return &object.Array{Elements: elements}, nil
+
+	case reflect.Map:
+		if v.IsNil() {
+			return &object.Null{}, nil
+		}
+		pairs := make(map[object.HashKey]object.HashPair, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			key, err := toObject(iter.Key())
+			if err != nil {
+				return nil, err
+			}
+			hashable, ok := key.(object.Hashable)
+			if !ok {
+				return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+			}
+			value, err := toObject(iter.Value())
+			if err != nil {
+				return nil, err
+			}
+			pairs[hashable.HashKey()] = object.HashPair{Key: key, Value: value}
+		}
+		return &object.Hash{Pairs: pairs}, nil
+
+	case reflect.Ptr, reflect.Interface:
+		return toObject(v.Elem())
+	}
+
+	return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+}
+
+// FromObject : convert a Monkey object to a Go value of type t, the inverse
+// of ToObject. Objects convert to interface{} as int64, bool, string,
+// []interface{}, map[interface{}]interface{} or nil. A nil obj converts like
+// null.
+func FromObject(obj object.Object, t reflect.Type) (reflect.Value, error) {
+	if obj == nil {
+		// no object at all, converted like null
+		obj = &object.Null{}
+	}
+	if t.Kind() == reflect.Interface && t.NumMethod() == 0 {
+		return fromObjectNatural(obj)
+	}
+	if reflect.TypeOf(obj).AssignableTo(t) {
+		return reflect.ValueOf(obj), nil
+	}
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Map, reflect.Interface:
+			return reflect.Zero(t), nil
+		}
+
+	case *object.Boolean:
+		if t.Kind() == reflect.Bool {
+			return reflect.ValueOf(obj.Value).Convert(t), nil
+		}
+
+	case *object.Integer:
+		v := reflect.New(t).Elem()
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if v.OverflowInt(obj.Value) {
+				return v, fmt.Errorf("cannot convert %d to %s: out of range", obj.Value, t)
+			}
+			v.SetInt(obj.Value)
+			return v, nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+			if obj.Value < 0 || v.OverflowUint(uint64(obj.Value)) {
+				return v, fmt.Errorf("cannot convert %d to %s: out of range", obj.Value, t)
+			}
+			v.SetUint(uint64(obj.Value))
+			return v, nil
+		}
+
+	case *object.String:
+		if t.Kind() == reflect.String {
+			return reflect.ValueOf(obj.Value).Convert(t), nil
+		}
+
+	case *object.Array:
+		if t.Kind() == reflect.Slice {
+			v := reflect.MakeSlice(t, len(obj.Elements), len(obj.Elements))
+			for i, element := range obj.Elements {
+				e, err := FromObject(element, t.Elem())
+				if err != nil {
+					return v, err
+				}
+				v.Index(i).Set(e)
+			}
+			return v, nil
+		}
+
+	case *object.Hash:
+		if t.Kind() == reflect.Map {
+			v := reflect.MakeMapWithSize(t, len(obj.Pairs))
+			for _, pair := range obj.Pairs {
+				key, err := FromObject(pair.Key, t.Key())
+				if err != nil {
+					return v, err
+				}
+				value, err := FromObject(pair.Value, t.Elem())
+				if err != nil {
+					return v, err
+				}
+				v.SetMapIndex(key, value)
+			}
+			return v, nil
+		}
+	}
+
+	if t.Kind() == reflect.Ptr {
+		v, err := FromObject(obj, t.Elem())
+		if err != nil {
+			return v, err
+		}
+		p := reflect.New(t.Elem())
+		p.Elem().Set(v)
+		return p, nil
+	}
+
+	return reflect.Value{}, fmt.Errorf("cannot convert %s to %s", obj.Type(), t)
+}
+
+// fromObjectNatural : convert obj to the Go value it most naturally maps to
+func fromObjectNatural(obj object.Object) (reflect.Value, error) {
+	anyType := reflect.TypeOf((*interface{})(nil)).Elem()
+
+	switch obj := obj.(type) {
+	case *object.Null:
+		return reflect.Zero(anyType), nil
+	case *object.Boolean:
+		return reflect.ValueOf(obj.Value), nil
+	case *object.Integer:
+		return reflect.ValueOf(obj.Value), nil
+	case *object.String:
+		return reflect.ValueOf(obj.Value), nil
+	case *object.Array:
+		return FromObject(obj, reflect.TypeOf([]interface{}{}))
+	case *object.Hash:
+		return FromObject(obj, reflect.TypeOf(map[interface{}]interface{}{}))
+	default:
+		return reflect.ValueOf(obj), nil
+	}
+}
+
+// Function : wrap a Go function as a builtin. Its arguments are converted
+// with FromObject and its result with ToObject. The function may return
+// nothing, a value, an error, or a value and an error; a non-nil error is
+// returned to the script as an error object.
+func Function(name string, fn interface{}) (*object.Builtin, error) {
+	v := reflect.ValueOf(fn)
+	if v.Kind() != reflect.Func {
+		return nil, fmt.Errorf("%s is not a function: %T", name, fn)
+	}
+
+	t := v.Type()
+	returnsError := t.NumOut() > 0 && t.Out(t.NumOut()-1) == errorType
+	numValues := t.NumOut()
+	if returnsError {
+		numValues--
+	}
+	if numValues > 1 {
+		return nil, fmt.Errorf("%s returns more than one value", name)
+	}
+
+	builtin := func(args ...object.Object) object.Object {
+		in, errObj := convertArguments(name, t, args)
+		if errObj != nil {
+			return errObj
+		}
+
+		out := v.Call(in)
+		if returnsError && !out[len(out)-1].IsNil() {
+			return &object.Error{Message: out[len(out)-1].Interface().(error).Error()}
+		}
+		if numValues == 0 {
+			return nil
+		}
+
+		result, err := toObject(out[0])
+		if err != nil {
+			return &object.Error{Message: name + ": " + err.Error()}
+		}
+		return result
+	}
+	return &object.Builtin{Name: name, Fn: builtin}, nil
+}
+
+// convertArguments : convert the arguments of a call to the parameter types
+// of a function of type t
+func convertArguments(name string, t reflect.Type, args []object.Object) ([]reflect.Value, *object.Error) {
+	numParams := t.NumIn()
+	if t.IsVariadic() {
+		numParams--
+		if len(args) < numParams {
+			return nil, &object.Error{Message: fmt.Sprintf(
+				"wrong number of arguments: want at least %d, got=%d", numParams, len(args))}
+		}
+	} else if len(args) != numParams {
+		return nil, &object.Error{Message: fmt.Sprintf(
+			"wrong number of arguments: want=%d, got=%d", numParams, len(args))}
+	}
+
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var paramType reflect.Type
+		if i < numParams {
+			paramType = t.In(i)
+		} else {
+			paramType = t.In(numParams).Elem()
+		}
+
+		v, err := FromObject(arg, paramType)
+		if err != nil {
+			return nil, &object.Error{Message: fmt.Sprintf(
+				"argument %d to %s: %s", i+1, name, err)}
+		}
+		in[i] = v
+	}
+	return in, nil
+}
@@ -0,0 +1,214 @@
+package monkey
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+func TestToObject(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string // Inspect of the object
+	}{
+		{42, "42"},
+		{int8(-3), "-3"},
+		{uint16(7), "7"},
+		{true, "true"},
+		{"hello", "hello"},
+		{[]int{1, 2, 3}, "[1, 2, 3]"},
+		{[2]bool{true, false}, "[true, false]"},
+		{map[string]int{"b": 2, "a": 1}, "{a: 1, b: 2}"},
+		{[]interface{}{1, "x", nil}, "[1, x, null]"},
+		{nil, "null"},
+		{(*int)(nil), "null"},
+		{&object.Integer{Value: 5}, "5"},
+	}
+
+	for _, tt := range tests {
+		obj, err := ToObject(tt.input)
+		if err != nil {
+			t.Errorf("ToObject(%#v) returned error: %s", tt.input, err)
+			continue
+		}
+		if obj.Inspect() != tt.expected {
+			t.Errorf("ToObject(%#v) wrong. want=%q, got=%q", tt.input, tt.expected, obj.Inspect())
+		}
+	}
+}
+
+func TestToObjectErrors(t *testing.T) {
+	tests := []struct {
+		input    interface{}
+		expected string
+	}{
+		{3.5, "cannot convert float64 to a Monkey value"},
+		{uint64(1 << 63), "cannot convert 9223372036854775808 to INTEGER: out of range"},
+		{map[[2]int]int{{1, 2}: 3}, "unusable as hash key: ARRAY"},
+	}
+
+	for _, tt := range tests {
+		_, err := ToObject(tt.input)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("ToObject(%#v) error wrong. want=%q, got=%v", tt.input, tt.expected, err)
+		}
+	}
+}
+
+func TestFromObjectRoundTrip(t *testing.T) {
+	tests := []interface{}{
+		int64(42),
+		int32(-7),
+		uint8(255),
+		true,
+		"hello",
+		[]int{1, 2, 3},
+		[][]string{{"a"}, {"b", "c"}},
+		map[string]bool{"yes": true, "no": false},
+		map[int][]int{1: {2, 3}},
+	}
+
+	for _, input := range tests {
+		obj, err := ToObject(input)
+		if err != nil {
+			t.Fatalf("ToObject(%#v) returned error: %s", input, err)
+		}
+		v, err := FromObject(obj, reflect.TypeOf(input))
+		if err != nil {
+			t.Errorf("FromObject(%s) returned error: %s", obj.Inspect(), err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), input) {
+			t.Errorf("round trip wrong. want=%#v, got=%#v", input, v.Interface())
+		}
+	}
+}
+
+func TestFromObjectNatural(t *testing.T) {
+	obj, _ := ToObject([]interface{}{1, true, "s", nil, map[string]int{"k": 1}})
+	v, err := FromObject(obj, reflect.TypeOf((*interface{})(nil)).Elem())
+	if err != nil {
+		t.Fatalf("FromObject returned error: %s", err)
+	}
+
+	expected := []interface{}{int64(1), true, "s", nil, map[interface{}]interface{}{"k": int64(1)}}
+	if !reflect.DeepEqual(v.Interface(), expected) {
+		t.Errorf("natural conversion wrong. want=%#v, got=%#v", expected, v.Interface())
+	}
+}
+
+func TestFromObjectErrors(t *testing.T) {
+	tests := []struct {
+		obj      object.Object
+		target   interface{}
+		expected string
+	}{
+		{&object.Integer{Value: 300}, int8(0), "cannot convert 300 to int8: out of range"},
+		{&object.Integer{Value: -1}, uint(0), "cannot convert -1 to uint: out of range"},
+		{&object.Boolean{Value: true}, 0, "cannot convert BOOLEAN to int"},
+		{&object.Null{}, "", "cannot convert NULL to string"},
+	}
+
+	for _, tt := range tests {
+		_, err := FromObject(tt.obj, reflect.TypeOf(tt.target))
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("FromObject(%s) error wrong. want=%q, got=%v", tt.obj.Inspect(), tt.expected, err)
+		}
+	}
+}
+
+func TestFromObjectNil(t *testing.T) {
+	for _, target := range []interface{}{(*int)(nil), []string(nil), map[string]int(nil), new(interface{})} {
+		typ := reflect.TypeOf(target)
+		if typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Interface {
+			typ = typ.Elem()
+		}
+		v, err := FromObject(nil, typ)
+		if err != nil {
+			t.Fatalf("FromObject(nil, %s) unexpected error: %s", typ, err)
+		}
+		if !v.IsZero() {
+			t.Errorf("FromObject(nil, %s) wrong. want zero value, got=%v", typ, v)
+		}
+	}
+
+	if _, err := FromObject(nil, reflect.TypeOf(0)); err == nil || err.Error() != "cannot convert NULL to int" {
+		t.Errorf("FromObject(nil, int) error wrong. got=%v", err)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	for name, interp := range newInterpreters(t) {
+		mustRegister(t, interp, "checkLength", func(n int, s string) (bool, error) {
+			if n < 0 {
+				return false, errors.New("negative length")
+			}
+			return len(s) == n, nil
+		})
+		mustRegister(t, interp, "greeting", func() string { return "hello" })
+		mustRegister(t, interp, "sum", func(ns ...int) int {
+			total := 0
+			for _, n := range ns {
+				total += n
+			}
+			return total
+		})
+		mustRegister(t, interp, "count", func(xs []int) int { return len(xs) })
+		mustRegister(t, interp, "nothing", func() {})
+
+		tests := []struct {
+			input    string
+			expected string // Inspect of the result, or the error message
+		}{
+			{"checkLength(5, greeting())", "true"},
+			{"if (checkLength(4, greeting())) { 1 } else { 2 }", "2"},
+			{"checkLength(5, greeting()) == true", "true"},
+			{"checkLength(-1, greeting())", "negative length"},
+			{"checkLength(1)", "wrong number of arguments: want=2, got=1"},
+			{"checkLength(true, greeting())", "argument 1 to checkLength: cannot convert BOOLEAN to int"},
+			{"sum()", "0"},
+			{"sum(1, 2, 3) * 2", "12"},
+			{"let s = fn(x) { sum(x, x) }; s(21)", "42"},
+			{"count(1)", "argument 1 to count: cannot convert INTEGER to []int"},
+			{"nothing()", "null"},
+		}
+
+		for _, tt := range tests {
+			result, err := interp.Eval(tt.input)
+			var got string
+			if err != nil {
+				got = err.Error()
+			} else {
+				got = result.Inspect()
+			}
+			if got != tt.expected {
+				t.Errorf("[%s] %s wrong. want=%q, got=%q", name, tt.input, tt.expected, got)
+			}
+		}
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	interp, _ := New()
+
+	err := interp.Register("notFunc", 5)
+	if err == nil || !strings.Contains(err.Error(), "is not a function") {
+		t.Errorf("expected error registering a non-function, got=%v", err)
+	}
+
+	err = interp.Register("pair", func() (int, int) { return 1, 2 })
+	if err == nil || err.Error() != "pair returns more than one value" {
+		t.Errorf("expected error registering two results, got=%v", err)
+	}
+}
+
+func mustRegister(t *testing.T, interp *Interpreter, name string, fn interface{}) {
+	t.Helper()
+
+	if err := interp.Register(name, fn); err != nil {
+		t.Fatalf("Register(%q) returned error: %s", name, err)
+	}
+}
@@ -0,0 +1,154 @@
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/rockspore/monkey-interpreter/engine"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/object"
+	"github.com/rockspore/monkey-interpreter/optimizer"
+	"github.com/rockspore/monkey-interpreter/parser"
+)
+
+// Interpreter : runs Monkey source against globals that persist between
+// calls to Eval. It is safe for concurrent use: its methods run one at a
+// time, so a long Eval delays the others unless bounded with a context, and
+// host functions must not call back into the Interpreter running them.
+type Interpreter struct {
+	mu  sync.Mutex
+	eng engine.Engine
+}
+
+// ParseError : returned by Eval when the source does not parse
+type ParseError struct {
+	Errors []string
+}
+
+func (e *ParseError) Error() string {
+	return "parse errors:\n\t" + strings.Join(e.Errors, "\n\t")
+}
+
+// config : the settings of a new Interpreter
+type config struct {
+	engine string
+	passes []optimizer.Pass
+	limits engine.Limits
+}
+
+// Option : configures an Interpreter created by New
+type Option func(*config)
+
+// WithEngine : run programs on the named engine, see engine.Names
+func WithEngine(name string) Option {
+	return func(c *config) {
+		c.engine = name
+	}
+}
+
+// WithPasses : optimize programs with the given passes instead of
+// optimizer.DefaultPasses; no passes disables optimization
+func WithPasses(passes ...optimizer.Pass) Option {
+	return func(c *config) {
+		c.passes = passes
+	}
+}
+
+// WithLimits : bound every Eval and Call, see engine.Limits
+func WithLimits(limits engine.Limits) Option {
+	return func(c *config) {
+		c.limits = limits
+	}
+}
+
+// New : create an interpreter, by default evaluating the tree of optimized
+// programs
+func New(opts ...Option) (*Interpreter, error) {
+	c := &config{engine: "eval", passes: optimizer.DefaultPasses}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	eng, err := engine.New(c.engine, c.passes...)
+	if err != nil {
+		return nil, err
+	}
+	eng.SetLimits(c.limits)
+	return &Interpreter{eng: eng}, nil
+}
+
+// Eval : parse and run src, returning the value of its last statement. Parse
+// failures are returned as *ParseError, and panics, e.g. in host functions,
+// as errors.
+func (i *Interpreter) Eval(src string) (object.Object, error) {
+	return i.EvalContext(context.Background(), src)
+}
+
+// EvalContext : parse and run src like Eval, failing once ctx is done with
+// an error matching engine.ErrAborted
+func (i *Interpreter) EvalContext(ctx context.Context, src string) (result object.Object, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	defer recoverPanic(&err)
+
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		return nil, &ParseError{Errors: p.Errors()}
+	}
+
+	return i.eng.RunContext(ctx, program)
+}
+
+// Set : bind a global name to a value
+func (i *Interpreter) Set(name string, val object.Object) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.eng.Set(name, val)
+}
+
+// Get : return the value bound to a global name
+func (i *Interpreter) Get(name string) (object.Object, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.eng.Get(name)
+}
+
+// Register : bind a global name to a Go function, wrapped with Function
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	builtin, err := Function(name, fn)
+	if err != nil {
+		return err
+	}
+	i.Set(name, builtin)
+	return nil
+}
+
+// Call : call the function bound to a global name
+func (i *Interpreter) Call(name string, args ...object.Object) (object.Object, error) {
+	return i.CallContext(context.Background(), name, args...)
+}
+
+// CallContext : call the function bound to a global name like Call, failing
+// once ctx is done
+func (i *Interpreter) CallContext(ctx context.Context, name string, args ...object.Object) (result object.Object, err error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	defer recoverPanic(&err)
+
+	fn, ok := i.eng.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("identifier not found: %s", name)
+	}
+	return i.eng.CallContext(ctx, fn, args...)
+}
+
+// recoverPanic : turn a panic while running a script, e.g. raised by a host
+// function, into the error returned to the embedding program
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("panic: %v", r)
+	}
+}
@@ -0,0 +1,215 @@
+package monkey
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/engine"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+func newInterpreters(t *testing.T) map[string]*Interpreter {
+	t.Helper()
+
+	interpreters := map[string]*Interpreter{}
+	for _, name := range engine.Names() {
+		interp, err := New(WithEngine(name))
+		if err != nil {
+			t.Fatalf("New(WithEngine(%q)) returned error: %s", name, err)
+		}
+		interpreters[name] = interp
+	}
+
+	unoptimized, err := New(WithPasses())
+	if err != nil {
+		t.Fatalf("New(WithPasses()) returned error: %s", err)
+	}
+	interpreters["unoptimized"] = unoptimized
+	return interpreters
+}
+
+func testInteger(t *testing.T, name string, obj object.Object, err error, expected int64) {
+	t.Helper()
+
+	if err != nil {
+		t.Errorf("[%s] unexpected error: %s", name, err)
+		return
+	}
+	result, ok := obj.(*object.Integer)
+	if !ok || result.Value != expected {
+		t.Errorf("[%s] result wrong. want=%d, got=%v", name, expected, obj)
+	}
+}
+
+func TestEval(t *testing.T) {
+	for name, interp := range newInterpreters(t) {
+		if _, err := interp.Eval("let double = fn(x) { x * 2 };"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", name, err)
+		}
+
+		result, err := interp.Eval("double(21)")
+		testInteger(t, name, result, err, 42)
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	for name, interp := range newInterpreters(t) {
+		_, err := interp.Eval("let = 1;")
+		parseErr, ok := err.(*ParseError)
+		if !ok {
+			t.Errorf("[%s] expected *ParseError, got=%T (%v)", name, err, err)
+		} else if len(parseErr.Errors) == 0 {
+			t.Errorf("[%s] ParseError without messages", name)
+		}
+
+		_, err = interp.Eval("fn(a, a) { a }(1, 2)")
+		if parseErr, ok := err.(*ParseError); !ok || len(parseErr.Errors) != 1 ||
+			parseErr.Errors[0] != "duplicate parameter a" {
+			t.Errorf("[%s] duplicate parameter error wrong. got=%v", name, err)
+		}
+
+		_, err = interp.Eval("1 + true")
+		if err == nil || err.Error() != "type mismatch: INTEGER + BOOLEAN" {
+			t.Errorf("[%s] runtime error wrong. got=%v", name, err)
+		}
+	}
+}
+
+func TestSetGetCall(t *testing.T) {
+	for name, interp := range newInterpreters(t) {
+		interp.Set("limit", &object.Integer{Value: 100})
+
+		if _, err := interp.Eval("let clamp = fn(x) { if (x > limit) { limit } else { x } }; let seen = true;"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", name, err)
+		}
+
+		seen, ok := interp.Get("seen")
+		if !ok || seen.Inspect() != "true" {
+			t.Errorf("[%s] Get(seen) wrong. got=%v, %t", name, seen, ok)
+		}
+
+		result, err := interp.Call("clamp", &object.Integer{Value: 250})
+		testInteger(t, name, result, err, 100)
+		result, err = interp.Call("clamp", &object.Integer{Value: 7})
+		testInteger(t, name, result, err, 7)
+
+		if _, err := interp.Eval("let affine = fn(x) { x * 2 + 5 };"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", name, err)
+		}
+		result, err = interp.Call("affine", &object.Integer{Value: 4})
+		testInteger(t, name, result, err, 13)
+
+		if _, err := interp.Call("missing"); err == nil || err.Error() != "identifier not found: missing" {
+			t.Errorf("[%s] Call(missing) error wrong. got=%v", name, err)
+		}
+		if _, err := interp.Call("clamp"); err == nil || err.Error() != "wrong number of arguments: want=1, got=0" {
+			t.Errorf("[%s] Call(clamp) error wrong. got=%v", name, err)
+		}
+	}
+}
+
+func TestPanicsAreErrors(t *testing.T) {
+	for name, interp := range newInterpreters(t) {
+		mustRegister(t, interp, "explode", func() int { panic("boom") })
+		interp.Set("host", &object.Builtin{Name: "host", Fn: func(args ...object.Object) object.Object {
+			return args[0]
+		}})
+
+		if _, err := interp.Eval("1 + explode()"); err == nil || err.Error() != "panic: boom" {
+			t.Errorf("[%s] Eval(explode()) error wrong. got=%v", name, err)
+		}
+		if _, err := interp.Call("host"); err == nil || !strings.HasPrefix(err.Error(), "panic: runtime error") {
+			t.Errorf("[%s] Call(host) error wrong. got=%v", name, err)
+		}
+
+		result, err := interp.Eval("1 + 1")
+		testInteger(t, name, result, err, 2)
+	}
+}
+
+func TestWithLimits(t *testing.T) {
+	for _, name := range engine.Names() {
+		interp, err := New(WithEngine(name), WithLimits(engine.Limits{MaxCallDepth: 20}))
+		if err != nil {
+			t.Fatalf("[%s] New returned error: %s", name, err)
+		}
+
+		if _, err := interp.Eval("let f = fn(x) { if (x == 0) { 0 } else { 1 + f(x - 1) } };"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", name, err)
+		}
+		result, err := interp.Call("f", &object.Integer{Value: 19})
+		testInteger(t, name, result, err, 19)
+		if _, err := interp.Eval("f(20)"); err == nil || err.Error() != "stack overflow: depth 21" {
+			t.Errorf("[%s] f(20) error wrong. got=%v", name, err)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+	for _, name := range engine.Names() {
+		interp, err := New(WithEngine(name), WithLimits(engine.Limits{MaxSteps: 1000}))
+		if err != nil {
+			t.Fatalf("[%s] New returned error: %s", name, err)
+		}
+
+		if _, err := interp.Eval("while (true) { 1 }"); err == nil || err.Error() != "step budget exceeded: 1000" || !errors.Is(err, engine.ErrAborted) {
+			t.Errorf("[%s] Eval error wrong. got=%v", name, err)
+		}
+		if _, err := interp.Eval("1 / 0"); errors.Is(err, engine.ErrAborted) {
+			t.Errorf("[%s] runtime error is ErrAborted: %v", name, err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		if _, err := interp.EvalContext(ctx, "let f = fn() { 1 }; f()"); err == nil || err.Error() != "evaluation cancelled: context canceled" ||
+			!errors.Is(err, engine.ErrAborted) || !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] EvalContext error wrong. got=%v", name, err)
+		}
+		if _, err := interp.Eval("let f = fn(x) { x * 2 };"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", name, err)
+		}
+		if _, err := interp.CallContext(ctx, "f", &object.Integer{Value: 1}); err == nil || err.Error() != "evaluation cancelled: context canceled" ||
+			!errors.Is(err, engine.ErrAborted) || !errors.Is(err, context.Canceled) {
+			t.Errorf("[%s] CallContext error wrong. got=%v", name, err)
+		}
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	for name, interp := range newInterpreters(t) {
+		if _, err := interp.Eval("let base = 100; let add = fn(x) { base + x };"); err != nil {
+			t.Fatalf("[%s] unexpected error: %s", name, err)
+		}
+
+		names := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+		var wg sync.WaitGroup
+		for g := range names {
+			wg.Add(1)
+			go func(g int) {
+				defer wg.Done()
+				for i := 0; i < 20; i++ {
+					if _, err := interp.Eval(fmt.Sprintf("let %s = add(%d);", names[g], i)); err != nil {
+						t.Errorf("[%s] Eval: unexpected error: %s", name, err)
+					}
+					result, err := interp.Call("add", &object.Integer{Value: int64(i)})
+					testInteger(t, name, result, err, int64(100+i))
+					interp.Set("base", &object.Integer{Value: 100})
+					if _, ok := interp.Get(names[g]); !ok {
+						t.Errorf("[%s] Get(%s) not found", name, names[g])
+					}
+				}
+			}(g)
+		}
+		wg.Wait()
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	if _, err := New(WithEngine("jit")); err == nil {
+		t.Errorf("expected error for unknown engine")
+	}
+}
@@ -1,5 +1,10 @@
 package object
 
+import (
+	"sort"
+	"sync"
+)
+
 // NewEnvironment : create a new environment
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
@@ -13,15 +18,57 @@ func NewEnclosedEnvironment(outer *Environment) *Environment {
 	return env
 }
 
-// Environment : the envrionment object
+// NewCallEnvironment : create a new environment for a function call at the
+// given call depth, extending the function's defining env
+func NewCallEnvironment(outer *Environment, depth int) *Environment {
+	env := NewEnclosedEnvironment(outer)
+	env.depth = depth
+	return env
+}
+
+// NewSlotEnvironment : create a new call environment whose bindings live in
+// an array with one slot per name, as laid out by the resolver
+func NewSlotEnvironment(outer *Environment, depth int, names []string) *Environment {
+	return &Environment{
+		outer: outer,
+		depth: depth,
+		slots: make([]Object, len(names)),
+		names: names,
+	}
+}
+
+// Environment : the envrionment object. Bindings, by name and in slots, are
+// guarded by a lock, so that an environment can be shared between goroutines,
+// e.g. by closures called from several of them or by runs each enclosing it
+// in an environment of their own.
 type Environment struct {
+	mu    sync.RWMutex
 	store map[string]Object
 	outer *Environment
+	depth int // number of function calls active when this env was created
+
+	slots []Object
+	names []string // names of the slots, for lookups by name
+}
+
+// Depth : return the call depth of the environment
+func (e *Environment) Depth() int {
+	return e.depth
 }
 
 // Get : return the object with the specified identifier from the environment
 func (e *Environment) Get(name string) (Object, bool) {
+	e.mu.RLock()
 	obj, ok := e.store[name]
+	if !ok {
+		for i, n := range e.names {
+			if n == name && e.slots[i] != nil {
+				obj, ok = e.slots[i], true
+				break
+			}
+		}
+	}
+	e.mu.RUnlock()
 	if !ok && e.outer != nil {
 		obj, ok = e.outer.Get(name)
 	}
@@ -30,6 +77,63 @@ func (e *Environment) Get(name string) (Object, bool) {
 
 // Set : set and return the objected with specified identifier
 func (e *Environment) Set(name string, val Object) Object {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for i, n := range e.names {
+		if n == name {
+			e.slots[i] = val
+			return val
+		}
+	}
+	if e.store == nil {
+		e.store = make(map[string]Object)
+	}
 	e.store[name] = val
 	return val
 }
+
+// Names : return the sorted names bound in this environment, not counting
+// outer environments
+func (e *Environment) Names() []string {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	names := []string{}
+	for name := range e.store {
+		names = append(names, name)
+	}
+	for i, name := range e.names {
+		if e.slots[i] != nil {
+			if _, ok := e.store[name]; !ok {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetSlot : return the object in a slot of the environment depth scopes out,
+// or nil when the slot has not been set
+func (e *Environment) GetSlot(depth int, slot int) Object {
+	env := e
+	for i := 0; i < depth && env != nil; i++ {
+		env = env.outer
+	}
+	if env == nil || slot >= len(env.slots) {
+		return nil
+	}
+
+	env.mu.RLock()
+	defer env.mu.RUnlock()
+	return env.slots[slot]
+}
+
+// SetSlot : set and return the object in a slot of this environment
+func (e *Environment) SetSlot(slot int, val Object) Object {
+	e.mu.Lock()
+	e.slots[slot] = val
+	e.mu.Unlock()
+	return val
+}
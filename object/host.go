@@ -0,0 +1,68 @@
+package object
+
+import "fmt"
+
+// Host types : the host can expose its own values to scripts as any type
+// implementing Object, with a Type of its choosing. They may implement any
+// of the interfaces below to support attribute and method syntax.
+
+// AttrGetter : host objects with attributes readable as obj.name
+type AttrGetter interface {
+	// GetAttr : return the attribute name, false when there is none
+	GetAttr(name string) (Object, bool)
+}
+
+// AttrSetter : host objects with attributes assignable as obj.name = value
+type AttrSetter interface {
+	// SetAttr : set the attribute name, failing when it does not exist or
+	// cannot take the value
+	SetAttr(name string, val Object) error
+}
+
+// MethodCaller : host objects with methods callable as obj.name(args)
+type MethodCaller interface {
+	// CallMethod : call the method name, false when there is none. As with
+	// builtins, a nil result stands for null.
+	CallMethod(name string, args ...Object) (Object, bool)
+}
+
+// GetAttr : return the attribute name of obj, or an error object when obj
+// has no such attribute
+func GetAttr(obj Object, name string) Object {
+	if getter, ok := obj.(AttrGetter); ok {
+		if val, ok := getter.GetAttr(name); ok {
+			return val
+		}
+	}
+	return &Error{Message: fmt.Sprintf("unknown attribute: %s.%s", obj.Type(), name)}
+}
+
+// SetAttr : set the attribute name of obj, returning an error object when it
+// cannot be set and nil otherwise
+func SetAttr(obj Object, name string, val Object) *Error {
+	setter, ok := obj.(AttrSetter)
+	if !ok {
+		return &Error{Message: fmt.Sprintf("cannot set attribute: %s.%s", obj.Type(), name)}
+	}
+	if err := setter.SetAttr(name, val); err != nil {
+		return &Error{Message: err.Error()}
+	}
+	return nil
+}
+
+// LookupMethod : call the method name of obj with args if it has one.
+// Otherwise return the attribute name for the caller to call as a function,
+// or an error object when there is no such attribute either.
+func LookupMethod(obj Object, name string, args []Object) (result Object, called bool) {
+	if caller, ok := obj.(MethodCaller); ok {
+		if result, ok := caller.CallMethod(name, args...); ok {
+			return result, true
+		}
+	}
+	if getter, ok := obj.(AttrGetter); ok {
+		if val, ok := getter.GetAttr(name); ok {
+			return val, false
+		}
+	}
+	return &Error{Message: fmt.Sprintf("unknown method: %s.%s", obj.Type(), name)}, false
+}
@@ -2,10 +2,14 @@ package object
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"sort"
 	"strings"
 
 	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/code"
 )
 
 // ObjectType : object type
@@ -18,6 +22,13 @@ const (
 	ReturnValueOBJ = "RETURN_VALUE"
 	ErrorOBJ       = "ERROR"
 	FunctionOBJ    = "FUNCTION"
+	BuiltinOBJ     = "BUILTIN"
+	StringOBJ      = "STRING"
+	ArrayOBJ       = "ARRAY"
+	HashOBJ        = "HASH"
+
+	CompiledFunctionOBJ = "COMPILED_FUNCTION"
+	ClosureOBJ          = "CLOSURE"
 )
 
 // Object : object interface
@@ -87,6 +98,42 @@ func (rv *ReturnValue) Type() ObjectType {
 // Error : error object
 type Error struct {
 	Message string
+	Aborted bool  // evaluation was stopped by a timeout, cancellation or budget
+	Cause   error // error of the context that stopped the evaluation, if any
+}
+
+// AsError : return the error object as a Go error, an AbortError when it
+// stopped the evaluation
+func (e *Error) AsError() error {
+	if e.Aborted {
+		return &AbortError{Message: e.Message, Err: e.Cause}
+	}
+	return errors.New(e.Message)
+}
+
+// ErrAborted : matched by errors.Is against the errors of evaluations
+// stopped by a timeout, cancellation or step budget
+var ErrAborted = errors.New("evaluation aborted")
+
+// AbortError : the error of an evaluation stopped before it finished
+type AbortError struct {
+	Message string
+	Err     error // error of the context that stopped it, nil for a budget
+}
+
+// Error : return the message
+func (e *AbortError) Error() string {
+	return e.Message
+}
+
+// Is : report whether target is ErrAborted
+func (e *AbortError) Is(target error) bool {
+	return target == ErrAborted
+}
+
+// Unwrap : return the error of the context that stopped the evaluation
+func (e *AbortError) Unwrap() error {
+	return e.Err
 }
 
 // Inspect : return error message
@@ -103,6 +150,7 @@ func (e *Error) Type() ObjectType {
 type Function struct {
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
+	Locals     []string // slot names when the body has been resolved
 	Env        *Environment
 }
 
@@ -128,3 +176,150 @@ func (f *Function) Inspect() string {
 func (f *Function) Type() ObjectType {
 	return FunctionOBJ
 }
+
+// CompiledFunction : function object holding bytecode instructions
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Lines         []code.SourceLine
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string // names of the local slots, for errors
+}
+
+// Inspect : return compiled function object
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Type : return compiled function type
+func (cf *CompiledFunction) Type() ObjectType {
+	return CompiledFunctionOBJ
+}
+
+// Closure : compiled function together with its captured free variables
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+// Inspect : return closure object
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Type : return closure type
+func (c *Closure) Type() ObjectType {
+	return ClosureOBJ
+}
+
+// BuiltinFunction : a function implemented by the host. A nil result stands
+// for null.
+type BuiltinFunction func(args ...Object) Object
+
+// Builtin : builtin function object
+type Builtin struct {
+	Name string
+	Fn   BuiltinFunction
+}
+
+// Inspect : return builtin function object
+func (b *Builtin) Inspect() string {
+	return "builtin " + b.Name
+}
+
+// Type : return builtin type
+func (b *Builtin) Type() ObjectType {
+	return BuiltinOBJ
+}
+
+// String : string object, only created by the host
+type String struct {
+	Value string
+}
+
+// Inspect : return string value
+func (s *String) Inspect() string {
+	return s.Value
+}
+
+// Type : return string type
+func (s *String) Type() ObjectType {
+	return StringOBJ
+}
+
+// Array : array object, only created by the host
+type Array struct {
+	Elements []Object
+}
+
+// Inspect : return array elements
+func (a *Array) Inspect() string {
+	elements := []string{}
+	for _, e := range a.Elements {
+		elements = append(elements, e.Inspect())
+	}
+	return "[" + strings.Join(elements, ", ") + "]"
+}
+
+// Type : return array type
+func (a *Array) Type() ObjectType {
+	return ArrayOBJ
+}
+
+// HashKey : identifies the value of a hashable object
+type HashKey struct {
+	Type  ObjectType
+	Value uint64
+}
+
+// Hashable : objects usable as hash keys
+type Hashable interface {
+	HashKey() HashKey
+}
+
+// HashKey : return integer hash key
+func (i *Integer) HashKey() HashKey {
+	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
+}
+
+// HashKey : return boolean hash key
+func (b *Boolean) HashKey() HashKey {
+	var value uint64
+	if b.Value {
+		value = 1
+	}
+	return HashKey{Type: b.Type(), Value: value}
+}
+
+// HashKey : return string hash key
+func (s *String) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write([]byte(s.Value))
+	return HashKey{Type: s.Type(), Value: h.Sum64()}
+}
+
+// HashPair : a key and its value in a hash
+type HashPair struct {
+	Key   Object
+	Value Object
+}
+
+// Hash : hash object, only created by the host
+type Hash struct {
+	Pairs map[HashKey]HashPair
+}
+
+// Inspect : return hash pairs, sorted so that the output is stable
+func (h *Hash) Inspect() string {
+	pairs := []string{}
+	for _, pair := range h.Pairs {
+		pairs = append(pairs, pair.Key.Inspect()+": "+pair.Value.Inspect())
+	}
+	sort.Strings(pairs)
+	return "{" + strings.Join(pairs, ", ") + "}"
+}
+
+// Type : return hash type
+func (h *Hash) Type() ObjectType {
+	return HashOBJ
+}
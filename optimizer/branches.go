@@ -0,0 +1,86 @@
+package optimizer
+
+import (
+	"github.com/rockspore/monkey-interpreter/ast"
+)
+
+// EliminateDeadBranches : replace if expressions with a literal boolean
+// condition by the branch that is always taken. Blocks share their
+// enclosing environment, so the branch's statements are spliced into the
+// enclosing statement list; inside other expressions only branches holding a
+// single expression are replaced.
+func EliminateDeadBranches(program *ast.Program) *ast.Program {
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		switch node := node.(type) {
+		case *ast.Program:
+			node.Statements = eliminateInStatements(node.Statements)
+		case *ast.BlockStatement:
+			node.Statements = eliminateInStatements(node.Statements)
+		case ast.Expression:
+			return eliminateInExpression(node)
+		}
+		return node
+	})
+	return program
+}
+
+// takenBranch : return the branch an if expression always takes, which is
+// nil when a false condition has no alternative
+func takenBranch(e ast.Expression) (*ast.BlockStatement, bool) {
+	ie, ok := e.(*ast.IfExpression)
+	if !ok {
+		return nil, false
+	}
+	condition, ok := ie.Condition.(*ast.Boolean)
+	if !ok {
+		return nil, false
+	}
+
+	if condition.Value {
+		return ie.Consequence, true
+	}
+	return ie.Alternative, true
+}
+
+func eliminateInExpression(e ast.Expression) ast.Expression {
+	branch, ok := takenBranch(e)
+	if !ok || branch == nil || len(branch.Statements) != 1 {
+		return e
+	}
+
+	if es, ok := branch.Statements[0].(*ast.ExpressionStatement); ok && es.Expression != nil {
+		return es.Expression
+	}
+	return e
+}
+
+func eliminateInStatements(stmts []ast.Statement) []ast.Statement {
+	result := make([]ast.Statement, 0, len(stmts))
+
+	for i, s := range stmts {
+		last := i == len(stmts)-1
+
+		es, ok := s.(*ast.ExpressionStatement)
+		if !ok {
+			result = append(result, s)
+			continue
+		}
+		branch, ok := takenBranch(es.Expression)
+		if !ok {
+			result = append(result, s)
+			continue
+		}
+
+		switch {
+		case branch != nil && len(branch.Statements) > 0:
+			result = append(result, branch.Statements...)
+		case !last:
+			// evaluates to null or nothing, and nobody sees the value
+		default:
+			// the value of the last statement is the value of the block
+			result = append(result, s)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,114 @@
+package optimizer
+
+import (
+	"strconv"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/token"
+)
+
+// FoldConstants : replace prefix and infix expressions over integer and
+// boolean literals with their value. Expressions that would fail at runtime,
+// such as type mismatches or division by zero, are left alone.
+func FoldConstants(program *ast.Program) *ast.Program {
+	ast.Modify(program, foldExpression)
+	return program
+}
+
+func foldExpression(node ast.Node) ast.Node {
+	switch node := node.(type) {
+	case *ast.PrefixExpression:
+		return foldPrefix(node)
+	case *ast.InfixExpression:
+		return foldInfix(node)
+	}
+	return node
+}
+
+func foldPrefix(pe *ast.PrefixExpression) ast.Expression {
+	switch right := pe.Right.(type) {
+	case *ast.IntegerLiteral:
+		switch pe.Operator {
+		case "-":
+			return integerLiteral(pe.Token, -right.Value)
+		case "!":
+			return booleanLiteral(pe.Token, false)
+		}
+	case *ast.Boolean:
+		if pe.Operator == "!" {
+			return booleanLiteral(pe.Token, !right.Value)
+		}
+	}
+	return pe
+}
+
+func foldInfix(ie *ast.InfixExpression) ast.Expression {
+	switch left := ie.Left.(type) {
+	case *ast.IntegerLiteral:
+		right, ok := ie.Right.(*ast.IntegerLiteral)
+		if !ok {
+			return ie
+		}
+		return foldIntegerInfix(ie, left.Value, right.Value)
+
+	case *ast.Boolean:
+		right, ok := ie.Right.(*ast.Boolean)
+		if !ok {
+			return ie
+		}
+		switch ie.Operator {
+		case "==":
+			return booleanLiteral(ie.Token, left.Value == right.Value)
+		case "!=":
+			return booleanLiteral(ie.Token, left.Value != right.Value)
+		}
+	}
+	return ie
+}
+
+func foldIntegerInfix(ie *ast.InfixExpression, left int64, right int64) ast.Expression {
+	switch ie.Operator {
+	case "+":
+		return integerLiteral(ie.Token, left+right)
+	case "-":
+		return integerLiteral(ie.Token, left-right)
+	case "*":
+		return integerLiteral(ie.Token, left*right)
+	case "/":
+		if right == 0 {
+			return ie
+		}
+		return integerLiteral(ie.Token, left/right)
+	case "<":
+		return booleanLiteral(ie.Token, left < right)
+	case "<=":
+		return booleanLiteral(ie.Token, left <= right)
+	case ">":
+		return booleanLiteral(ie.Token, left > right)
+	case ">=":
+		return booleanLiteral(ie.Token, left >= right)
+	case "==":
+		return booleanLiteral(ie.Token, left == right)
+	case "!=":
+		return booleanLiteral(ie.Token, left != right)
+	}
+	return ie
+}
+
+// integerLiteral : create a literal positioned at the folded expression
+func integerLiteral(at token.Token, value int64) *ast.IntegerLiteral {
+	literal := strconv.FormatInt(value, 10)
+	return &ast.IntegerLiteral{
+		Token: token.Token{Type: token.INT, Literal: literal, Line: at.Line},
+		Value: value,
+	}
+}
+
+// booleanLiteral : create a literal positioned at the folded expression
+func booleanLiteral(at token.Token, value bool) *ast.Boolean {
+	tok := token.Token{Type: token.FALSE, Literal: "false", Line: at.Line}
+	if value {
+		tok = token.Token{Type: token.TRUE, Literal: "true", Line: at.Line}
+	}
+	return &ast.Boolean{Token: tok, Value: value}
+}
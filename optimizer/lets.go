@@ -0,0 +1,68 @@
+package optimizer
+
+import (
+	"github.com/rockspore/monkey-interpreter/ast"
+)
+
+// RemoveUnusedLets : drop let statements inside functions that bind a
+// literal or function to a name never referenced anywhere in the program.
+// Top-level bindings are kept since they outlive the program in the
+// environment, and so is the last statement of a block, whose value is the
+// value of the block.
+func RemoveUnusedLets(program *ast.Program) *ast.Program {
+	declarations := map[*ast.Identifier]bool{}
+	referenced := map[string]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			declarations[node.Name] = true
+		case *ast.FunctionLiteral:
+			for _, p := range node.Parameters {
+				declarations[p] = true
+			}
+		case *ast.Identifier:
+			if !declarations[node] {
+				referenced[node.Value] = true
+			}
+		}
+		return true
+	})
+
+	removeUnused := func(stmts []ast.Statement) []ast.Statement {
+		result := make([]ast.Statement, 0, len(stmts))
+		for i, s := range stmts {
+			ls, ok := s.(*ast.LetStatement)
+			if ok && i != len(stmts)-1 && !referenced[ls.Name.Value] && isPure(ls.Value) {
+				continue
+			}
+			result = append(result, s)
+		}
+		return result
+	}
+
+	ast.Modify(program, func(node ast.Node) ast.Node {
+		fl, ok := node.(*ast.FunctionLiteral)
+		if !ok {
+			return node
+		}
+		ast.Modify(fl.Body, func(node ast.Node) ast.Node {
+			if block, ok := node.(*ast.BlockStatement); ok {
+				block.Statements = removeUnused(block.Statements)
+			}
+			return node
+		})
+		return node
+	})
+
+	return program
+}
+
+// isPure : report whether evaluating the expression can neither fail nor
+// have an effect
+func isPure(e ast.Expression) bool {
+	switch e.(type) {
+	case *ast.IntegerLiteral, *ast.Boolean, *ast.FunctionLiteral:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,19 @@
+package optimizer
+
+import (
+	"github.com/rockspore/monkey-interpreter/ast"
+)
+
+// Pass : an ast-to-ast transformation preserving the program's behavior
+type Pass func(program *ast.Program) *ast.Program
+
+// DefaultPasses : all passes, in the order they are most effective
+var DefaultPasses = []Pass{FoldConstants, EliminateDeadBranches, RemoveUnusedLets}
+
+// Optimize : run the given passes over the program in order
+func Optimize(program *ast.Program, passes ...Pass) *ast.Program {
+	for _, pass := range passes {
+		program = pass(program)
+	}
+	return program
+}
@@ -0,0 +1,147 @@
+package optimizer
+
+import (
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/evaluator"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/object"
+	"github.com/rockspore/monkey-interpreter/parser"
+)
+
+func parse(t *testing.T, input string) *ast.Program {
+	p := parser.New(lexer.New(input))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		t.Fatalf("parser errors for %q: %v", input, p.Errors())
+	}
+	return program
+}
+
+func TestFoldConstants(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"1 + 2 * 3", "7"},
+		{"-(5 + 5)", "-10"},
+		{"(1 < 2) == true", "true"},
+		{"!true != false", "false"},
+		{"!5", "false"},
+		{"x + 2 * 3", "(x + 6)"},
+		{"5 + true", "(5 + true)"},
+		{"-true", "(-true)"},
+		{"1 / 0", "(1 / 0)"},
+		{"let a = fn() { 2 * 21 };", "let a = fn()42;"},
+	}
+
+	for _, tt := range tests {
+		program := FoldConstants(parse(t, tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestEliminateDeadBranches(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"if (true) { 1 } else { 2 }", "1"},
+		{"if (false) { 1 } else { 2 }", "2"},
+		{"if (true) { let a = 1; a }; 3", "let a = 1;a3"},
+		{"if (false) { 1 }; 3", "3"},
+		{"if (false) { 1 }", "iffalse 1"},
+		{"let a = if (true) { 1 } else { 2 };", "let a = 1;"},
+		{"let a = if (true) { 1; 2 };", "let a = iftrue 12;"},
+		{"if (x) { 1 }", "ifx 1"},
+	}
+
+	for _, tt := range tests {
+		program := EliminateDeadBranches(parse(t, tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+func TestRemoveUnusedLets(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"fn() { let a = 1; 2 }", "fn()2"},
+		{"fn() { let a = fn() { 1 }; 2 }", "fn()2"},
+		{"fn() { let a = 1; a }", "fn()let a = 1;a"},
+		{"fn() { let a = b; 2 }", "fn()let a = b;2"},
+		{"fn() { let a = 1; }", "fn()let a = 1;"},
+		{"fn() { if (x) { let a = 1; 2 } }", "fn()ifx 2"},
+		{"let a = 1; 2", "let a = 1;2"},
+	}
+
+	for _, tt := range tests {
+		program := RemoveUnusedLets(parse(t, tt.input))
+		if program.String() != tt.expected {
+			t.Errorf("%s: wrong result. want=%q, got=%q", tt.input, tt.expected, program.String())
+		}
+	}
+}
+
+// semanticsInputs : programs the passes rewrite, which must evaluate the
+// same with and without every pass; the engine conformance tests run the
+// general cases with the default passes
+var semanticsInputs = []string{
+	// constant folding
+	"(5 + 10 * 2 + 15 / 3) * 2 + -10",
+	"(1 < 2) == true",
+	"!!5",
+	"-(5 + 5) * 2",
+	"1 / 0",
+	"5 + true; 5;",
+	"-true",
+	// dead branches
+	"if (true) { 10 }",
+	"if (false) { 10 }",
+	"if (1) { 10 }",
+	"if (1 > 2) { 10 } else { 20 }",
+	"9; if (true) { return 10; }; 8",
+	"if (10 > 1) { if (10 > 1) { return 10; } return 1; }",
+	"let i = 0; while (false) { let i = 1; }; i;",
+	// unused lets
+	"let f = fn() { let unused = 1; let i = 0; while (i < 3) { let i = i + 1; }; i }; f();",
+	"let f = fn() { let unused = fn() { 1 }; 2 }; f();",
+	"let f = fn() { let last = 1 }; f();",
+	"let f = fn() { if (true) { let a = 2; }; a * (3 + 4) }; f();",
+	"let f = fn() { if (false) { return 1; }; 2 }; f();",
+}
+
+func TestPassesPreserveSemantics(t *testing.T) {
+	configurations := map[string][]Pass{
+		"FoldConstants":         {FoldConstants},
+		"EliminateDeadBranches": {EliminateDeadBranches},
+		"RemoveUnusedLets":      {RemoveUnusedLets},
+		"DefaultPasses":         DefaultPasses,
+	}
+
+	for _, input := range semanticsInputs {
+		expected := evaluate(parse(t, input))
+
+		for name, passes := range configurations {
+			actual := evaluate(Optimize(parse(t, input), passes...))
+			if actual != expected {
+				t.Errorf("[%s] %s: result changed. want=%s, got=%s",
+					name, input, expected, actual)
+			}
+		}
+	}
+}
+
+func evaluate(program *ast.Program) string {
+	result := evaluator.Eval(program, object.NewEnvironment())
+	if result == nil {
+		return "<nil>"
+	}
+	return result.Inspect()
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strconv"
 
 	"github.com/rockspore/monkey-interpreter/ast"
@@ -13,12 +14,13 @@ import (
 const (
 	_ int = iota
 	LOWEST
+	ASSIGN      // obj.x = X
 	EQUALS      // ==
 	LESSGREATER // > or < or <= or >=
 	SUM         // +
 	PRODUCT     // *
 	PREFIX      // -X or !X
-	CALL        // myFunc(X)
+	CALL        // myFunc(X) or obj.x
 )
 
 var precedences = map[token.TokenType]int{
@@ -33,6 +35,8 @@ var precedences = map[token.TokenType]int{
 	token.SLASH:    PRODUCT,
 	token.ASTERISK: PRODUCT,
 	token.LPAREN:   CALL,
+	token.DOT:      CALL,
+	token.ASSIGN:   ASSIGN,
 }
 
 // Parser : definition of Parser struct
@@ -43,18 +47,34 @@ type Parser struct {
 	curToken  token.Token
 	peekToken token.Token
 
+	tokens   []token.Token     // every token read, up to and including EOF
+	curIndex int               // index of curToken in tokens
+	spans    map[ast.Node]Span // nil unless recording spans
+
+	traceOut   io.Writer // nil unless tracing
+	traceLevel int
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
 
+// Span : the tokens a node was parsed from, as inclusive indexes into
+// Tokens
+type Span struct {
+	First, Last int
+}
+
 type (
 	prefixParseFn func() ast.Expression
 	infixParseFn  func(ast.Expression) ast.Expression
 )
 
 // New : generates a new parser based on the input lexer
-func New(l *lexer.Lexer) *Parser {
-	p := &Parser{l: l, errors: []string{}}
+func New(l *lexer.Lexer, opts ...Option) *Parser {
+	p := &Parser{l: l, errors: []string{}, curIndex: -2}
+	for _, opt := range opts {
+		opt(p)
+	}
 
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIdentifier)
@@ -65,6 +85,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression)
 	p.registerPrefix(token.IF, p.parseIfExpression)
+	p.registerPrefix(token.WHILE, p.parseWhileExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral)
 
 	p.infixParseFns = make(map[token.TokenType]infixParseFn)
@@ -79,6 +100,8 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.GT, p.parseInfixExpression)
 	p.registerInfix(token.GE, p.parseInfixExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)
+	p.registerInfix(token.DOT, p.parseAttributeExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
 
 	// Read two tokens, so both curToken and peekToken are set
 	p.nextToken()
@@ -114,9 +137,53 @@ func (p *Parser) peekError(t token.TokenType) {
 	p.errors = append(p.errors, msg)
 }
 
+// WithSpans : record the tokens read and the span of every node parsed, for
+// Tokens and Span
+func WithSpans() Option {
+	return func(p *Parser) {
+		p.spans = map[ast.Node]Span{}
+	}
+}
+
+// Tokens : return every token read so far, or nil without WithSpans. With a
+// lexer created by lexer.NewWithTrivia they spell out the parsed source
+// exactly.
+func (p *Parser) Tokens() []token.Token {
+	return p.tokens
+}
+
+// Span : return the tokens a node returned by ParseProgram was parsed from,
+// if recorded with WithSpans. The span of a parenthesized expression includes
+// its parentheses, and the span of a statement its semicolon.
+func (p *Parser) Span(node ast.Node) (Span, bool) {
+	span, ok := p.spans[node]
+	return span, ok
+}
+
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
+	p.curIndex++
 	p.peekToken = p.l.NextToken()
+
+	if p.spans == nil {
+		return
+	}
+	if len(p.tokens) == 0 || p.tokens[len(p.tokens)-1].Type != token.EOF {
+		p.tokens = append(p.tokens, p.peekToken)
+	}
+}
+
+// markSpan : record that node was parsed from the tokens between first and
+// the current token
+func (p *Parser) markSpan(node ast.Node, first int) {
+	if node == nil || p.spans == nil {
+		return
+	}
+	last := p.curIndex
+	if last >= len(p.tokens) {
+		last = len(p.tokens) - 1
+	}
+	p.spans[node] = Span{First: first, Last: last}
 }
 
 func (p *Parser) registerPrefix(tokenType token.TokenType, fn prefixParseFn) {
@@ -139,19 +206,28 @@ func (p *Parser) ParseProgram() *ast.Program {
 		}
 		p.nextToken()
 	}
+	if p.spans != nil {
+		p.spans[program] = Span{First: 0, Last: len(p.tokens) - 1}
+	}
 
 	return program
 }
 
 func (p *Parser) parseStatement() ast.Statement {
+	first := p.curIndex
+
+	var stmt ast.Statement
 	switch p.curToken.Type {
 	case token.LET:
-		return p.parseLetStatement()
+		stmt = p.parseLetStatement()
 	case token.RETURN:
-		return p.parseReturnStatement()
+		stmt = p.parseReturnStatement()
 	default:
-		return p.parseExpressionStatement()
+		stmt = p.parseExpressionStatement()
 	}
+
+	p.markSpan(stmt, first)
+	return stmt
 }
 
 func (p *Parser) parseLetStatement() *ast.LetStatement {
@@ -162,6 +238,7 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 
 	stmt.Name = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.markSpan(stmt.Name, p.curIndex)
 
 	if !p.expectPeek(token.ASSIGN) {
 		return nil
@@ -193,7 +270,7 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 }
 
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
-	// defer untrace(trace("parseExpressionStatement"))
+	defer p.untrace(p.trace("parseExpressionStatement"))
 
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
 
@@ -212,14 +289,16 @@ func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 }
 
 func (p *Parser) parseExpression(precedence int) ast.Expression {
-	// defer untrace(trace("parseExpression"))
+	defer p.untrace(p.trace("parseExpression"))
 
 	prefix := p.prefixParseFns[p.curToken.Type]
 	if prefix == nil {
 		p.noPrefixParseFnError(p.curToken.Type)
 		return nil
 	}
+	first := p.curIndex
 	leftExp := prefix()
+	p.markSpan(leftExp, first)
 
 	for !p.peekTokenIs(token.SEMICOLON) && precedence < p.peekPrecedence() {
 		infix := p.infixParseFns[p.peekToken.Type]
@@ -230,6 +309,7 @@ func (p *Parser) parseExpression(precedence int) ast.Expression {
 		p.nextToken()
 
 		leftExp = infix(leftExp)
+		p.markSpan(leftExp, first)
 	}
 	return leftExp
 }
@@ -243,7 +323,7 @@ func (p *Parser) parseBoolean() ast.Expression {
 }
 
 func (p *Parser) parseIntegerLiteral() ast.Expression {
-	// defer untrace(trace("parseIntegerLiteral"))
+	defer p.untrace(p.trace("parseIntegerLiteral"))
 
 	il := &ast.IntegerLiteral{Token: p.curToken}
 
@@ -260,7 +340,7 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
-	// defer untrace(trace("parsePrefixExpression"))
+	defer p.untrace(p.trace("parsePrefixExpression"))
 
 	exp := &ast.PrefixExpression{
 		Token:    p.curToken,
@@ -275,7 +355,7 @@ func (p *Parser) parsePrefixExpression() ast.Expression {
 }
 
 func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
-	// defer untrace(trace("parseInfixExpression"))
+	defer p.untrace(p.trace("parseInfixExpression"))
 
 	exp := &ast.InfixExpression{
 		Token:    p.curToken,
@@ -335,9 +415,33 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseWhileExpression() ast.Expression {
+	exp := &ast.WhileExpression{Token: p.curToken}
+
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+
+	p.nextToken()
+	exp.Condition = p.parseExpression(LOWEST)
+
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+
+	exp.Consequence = p.parseBlockStatement()
+
+	return exp
+}
+
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
+	first := p.curIndex
 
 	p.nextToken() // skip the { token
 
@@ -348,6 +452,13 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 		}
 		p.nextToken()
 	}
+	if p.curTokenIs(token.EOF) {
+		msg := fmt.Sprintf("expected next token to be '%s'. got '%s' instead",
+			token.RBRACE, token.EOF)
+		p.errors = append(p.errors, msg)
+	}
+	block.EndToken = p.curToken
+	p.markSpan(block, first)
 
 	return block
 }
@@ -381,12 +492,21 @@ func (p *Parser) parseFunctionParameters() []*ast.Identifier {
 	p.nextToken()
 
 	ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	p.markSpan(ident, p.curIndex)
 	identifiers = append(identifiers, ident)
 
 	for p.peekTokenIs(token.COMMA) {
 		p.nextToken()
 		p.nextToken()
 		ident := &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		p.markSpan(ident, p.curIndex)
+		for _, seen := range identifiers {
+			if seen.Value == ident.Value {
+				msg := fmt.Sprintf("duplicate parameter %s", ident.Value)
+				p.errors = append(p.errors, msg)
+				break
+			}
+		}
 		identifiers = append(identifiers, ident)
 	}
 
@@ -403,6 +523,38 @@ func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
 	return exp
 }
 
+func (p *Parser) parseAttributeExpression(object ast.Expression) ast.Expression {
+	exp := &ast.AttributeExpression{Token: p.curToken, Object: object}
+
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	exp.Name = p.curToken.Literal
+
+	return exp
+}
+
+// parseAssignExpression : parse an assignment to an attribute, the only
+// assignable expression. Assignments group to the right.
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken}
+
+	attr, ok := target.(*ast.AttributeExpression)
+	if !ok {
+		if target != nil {
+			msg := fmt.Sprintf("cannot assign to %s", target)
+			p.errors = append(p.errors, msg)
+		}
+		return nil
+	}
+	exp.Target = attr
+
+	p.nextToken()
+	exp.Value = p.parseExpression(LOWEST)
+
+	return exp
+}
+
 func (p *Parser) parseCallArguments() []ast.Expression {
 	args := []ast.Expression{}
 
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/rockspore/monkey-interpreter/ast"
@@ -322,6 +323,18 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a + b + c * d / f + g)",
 			"add((((a + b) + ((c * d) / f)) + g))",
 		},
+		{
+			"-a.b * c.d(e).f",
+			"((-a.b) * c.d(e).f)",
+		},
+		{
+			"a.b = c.d = 1 + 2",
+			"(a.b = (c.d = (1 + 2)))",
+		},
+		{
+			"(a.b = 1) + a.b",
+			"((a.b = 1) + a.b)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -478,6 +491,48 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestWhileExpression(t *testing.T) {
+	input := `while (x < y) { x }`
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("program.Statements does not contain %d statements. got=%d\n",
+			1, len(program.Statements))
+	}
+
+	stmt, ok := program.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("program.Statements[0] is not ast.ExpressionStatement. got=%T",
+			program.Statements[0])
+	}
+
+	exp, ok := stmt.Expression.(*ast.WhileExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.WhileExpression. got=%T",
+			stmt.Expression)
+	}
+
+	if !testInfixExpression(t, exp.Condition, "x", "<", "y") {
+		return
+	}
+
+	if len(exp.Consequence.Statements) != 1 {
+		t.Errorf("consequence is not 1 statements. got=%d\n",
+			len(exp.Consequence.Statements))
+	}
+
+	consequence, ok := exp.Consequence.Statements[0].(*ast.ExpressionStatement)
+	if !ok {
+		t.Fatalf("Statements[0] is not ast.ExpressionStatement. got=%T",
+			exp.Consequence.Statements[0])
+	}
+
+	testIdentifier(t, consequence.Expression, "x")
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	input := `fn(x, y) { x + y; }`
 
@@ -552,6 +607,16 @@ func TestFunctionParameterParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestDuplicateParameters(t *testing.T) {
+	for _, input := range []string{"fn(a, a) { a }", "fn(a, b, a) { a }"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+		if errors := p.Errors(); len(errors) != 1 || errors[0] != "duplicate parameter a" {
+			t.Errorf("%s: wrong errors. got=%q", input, errors)
+		}
+	}
+}
 func TestCallExpressionParsing(t *testing.T) {
 	input := "add(1, 2 * 3, 4 + 5);"
 
@@ -642,6 +707,104 @@ func TestCallExpressionParameterParsing(t *testing.T) {
 	}
 }
 
+func TestAttributeExpressionParsing(t *testing.T) {
+	input := "req.header(name).length;"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	stmt := program.Statements[0].(*ast.ExpressionStatement)
+	length, ok := stmt.Expression.(*ast.AttributeExpression)
+	if !ok {
+		t.Fatalf("stmt.Expression is not ast.AttributeExpression. got=%T", stmt.Expression)
+	}
+	if length.Name != "length" {
+		t.Errorf("attribute name is not %q. got=%q", "length", length.Name)
+	}
+
+	call, ok := length.Object.(*ast.CallExpression)
+	if !ok {
+		t.Fatalf("attribute object is not ast.CallExpression. got=%T", length.Object)
+	}
+	header, ok := call.Function.(*ast.AttributeExpression)
+	if !ok {
+		t.Fatalf("call function is not ast.AttributeExpression. got=%T", call.Function)
+	}
+	if header.Name != "header" || !testIdentifier(t, header.Object, "req") {
+		t.Errorf("method wrong. got=%s", header)
+	}
+	if len(call.Arguments) != 1 || !testIdentifier(t, call.Arguments[0], "name") {
+		t.Errorf("method arguments wrong. got=%v", call.Arguments)
+	}
+}
+
+func TestAssignExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"x = 1", "cannot assign to x"},
+		{"a.b + 1 = 2", "cannot assign to (a.b + 1)"},
+		{"f().g() = 3", "cannot assign to f().g()"},
+		{"a.1", "expected next token to be 'IDENT'. got 'INT' instead"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("parse errors of %q wrong. expected=%q, got=%q", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestUnclosedBlock(t *testing.T) {
+	for _, input := range []string{"fn(x) { x", "if (x) { 1 } else {", "while (true) {"} {
+		p := New(lexer.New(input))
+		p.ParseProgram()
+
+		expected := "expected next token to be '}'. got 'EOF' instead"
+		errors := p.Errors()
+		if len(errors) != 1 || errors[0] != expected {
+			t.Errorf("parse errors of %q wrong. expected=%q, got=%q", input, expected, errors)
+		}
+	}
+}
+
+func TestProgramJSONRoundTrip(t *testing.T) {
+	inputs := []string{
+		"let x = 5; return x;",
+		"-a * !b + (c - d) / e == f != g < h >= i;",
+		"if (x <= y) { x } else { y }; if (true) {}",
+		"while (i > 0) { let i = i - 1; }",
+		"let add = fn(x, y) {\n  return x + y;\n};\nadd(1, fn() { 2 }(), 003);",
+		"req.status = req.header(name).length;",
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		data, err := ast.ToJSON(program)
+		if err != nil {
+			t.Fatalf("ToJSON(%q) returned error: %s", input, err)
+		}
+
+		decoded, err := ast.FromJSON(data)
+		if err != nil {
+			t.Fatalf("FromJSON(%s) returned error: %s", data, err)
+		}
+
+		if !reflect.DeepEqual(decoded, program) {
+			t.Errorf("round trip of %q wrong.\njson=%s\ngot=%s", input, data, decoded.String())
+		}
+	}
+}
+
 func checkParserErrors(t *testing.T, p *Parser) {
 	errors := p.Errors()
 	if len(errors) == 0 {
@@ -780,3 +943,36 @@ func testLiteralExpression(t *testing.T, exp ast.Expression, expected interface{
 	t.Errorf("type of exp not handled. got %T", exp)
 	return false
 }
+
+func TestSpans(t *testing.T) {
+	input := "let x = (1 + 2);"
+
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	if _, ok := p.Span(program); ok || p.Tokens() != nil {
+		t.Errorf("spans recorded without WithSpans")
+	}
+
+	p = New(lexer.New(input), WithSpans())
+	program = p.ParseProgram()
+	if len(p.Tokens()) != 10 {
+		t.Fatalf("wrong number of tokens. want=10, got=%d", len(p.Tokens()))
+	}
+
+	let := program.Statements[0].(*ast.LetStatement)
+	tests := []struct {
+		node     ast.Node
+		expected Span
+	}{
+		{program, Span{First: 0, Last: 9}},
+		{let, Span{First: 0, Last: 8}},
+		{let.Value, Span{First: 3, Last: 7}},
+	}
+
+	for _, tt := range tests {
+		span, ok := p.Span(tt.node)
+		if !ok || span != tt.expected {
+			t.Errorf("wrong span for %s. want=%+v, got=%+v, %t", tt.node, tt.expected, span, ok)
+		}
+	}
+}
@@ -2,35 +2,52 @@ package parser
 
 import (
 	"fmt"
+	"io"
 	"strings"
 )
 
-var traceLevel int = 0
-
 const traceIdentPlaceholder string = "\t"
 
-func identLevel() string {
-	return strings.Repeat(traceIdentPlaceholder, traceLevel-1)
+// Option : configures a Parser created by New
+type Option func(*Parser)
+
+// WithTrace : write the entry and exit of each parsing function to w,
+// indented by nesting depth
+func WithTrace(w io.Writer) Option {
+	return func(p *Parser) {
+		p.traceOut = w
+	}
 }
 
-func tracePrint(fs string) {
-	fmt.Printf("%s%s\n", identLevel(), fs)
+func (p *Parser) identLevel() string {
+	return strings.Repeat(traceIdentPlaceholder, p.traceLevel-1)
 }
 
-func incIdent() {
-	traceLevel = traceLevel + 1
+func (p *Parser) tracePrint(fs string) {
+	fmt.Fprintf(p.traceOut, "%s%s\n", p.identLevel(), fs)
 }
-func decIdent() {
-	traceLevel = traceLevel - 1
+
+func (p *Parser) incIdent() {
+	p.traceLevel = p.traceLevel + 1
+}
+
+func (p *Parser) decIdent() {
+	p.traceLevel = p.traceLevel - 1
 }
 
-func trace(msg string) string {
-	incIdent()
-	tracePrint("BEGIN " + msg)
+func (p *Parser) trace(msg string) string {
+	if p.traceOut == nil {
+		return msg
+	}
+	p.incIdent()
+	p.tracePrint("BEGIN " + msg)
 	return msg
 }
 
-func untrace(msg string) {
-	tracePrint("END " + msg)
-	decIdent()
+func (p *Parser) untrace(msg string) {
+	if p.traceOut == nil {
+		return
+	}
+	p.tracePrint("END " + msg)
+	p.decIdent()
 }
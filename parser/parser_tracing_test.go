@@ -0,0 +1,82 @@
+package parser
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/lexer"
+)
+
+func TestTrace(t *testing.T) {
+	expected := `BEGIN parseExpressionStatement
+	BEGIN parseExpression
+		BEGIN parsePrefixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parsePrefixExpression
+		BEGIN parseInfixExpression
+			BEGIN parseExpression
+				BEGIN parseIntegerLiteral
+				END parseIntegerLiteral
+			END parseExpression
+		END parseInfixExpression
+	END parseExpression
+END parseExpressionStatement
+`
+
+	var out bytes.Buffer
+	p := New(lexer.New("-1 + 2;"), WithTrace(&out))
+	p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if out.String() != expected {
+		t.Errorf("trace wrong.\nexpected=%q\ngot=%q", expected, out.String())
+	}
+}
+
+func TestTraceConcurrentParsers(t *testing.T) {
+	inputs := []string{"-1 + 2;", "let f = fn(x) { x * 2 }; f(3)", "if (a < b) { a } else { b }"}
+
+	expected := make([]string, len(inputs))
+	for i, input := range inputs {
+		var out bytes.Buffer
+		New(lexer.New(input), WithTrace(&out)).ParseProgram()
+		expected[i] = out.String()
+	}
+
+	var wg sync.WaitGroup
+	got := make([][]string, 8)
+	for g := range got {
+		got[g] = make([]string, len(inputs))
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i, input := range inputs {
+				var out bytes.Buffer
+				New(lexer.New(input), WithTrace(&out)).ParseProgram()
+				got[g][i] = out.String()
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	for g := range got {
+		for i := range inputs {
+			if got[g][i] != expected[i] {
+				t.Errorf("goroutine %d: trace of %q wrong.\nexpected=%q\ngot=%q",
+					g, inputs[i], expected[i], got[g][i])
+			}
+		}
+	}
+}
+
+func TestNoTraceByDefault(t *testing.T) {
+	p := New(lexer.New("-1 + 2;"))
+	p.ParseProgram()
+	if p.traceLevel != 0 {
+		t.Errorf("untraced parser changed trace level to %d", p.traceLevel)
+	}
+}
@@ -0,0 +1,238 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/compiler"
+	"github.com/rockspore/monkey-interpreter/engine"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/object"
+	"github.com/rockspore/monkey-interpreter/parser"
+)
+
+// command : a colon-command of the REPL
+type command struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+// commands : all colon-commands, set in init because :help lists them
+var commands []command
+
+func init() {
+	commands = []command{
+		{":ast", ":ast <source>", "print the syntax tree of source", (*session).printAST},
+		{":bytecode", ":bytecode <source>", "print the bytecode of source, compiled against the session", (*session).printBytecode},
+		{":env", ":env", "list the bindings of the session", (*session).printEnv},
+		{":help", ":help", "list the commands", (*session).printHelp},
+		{":load", ":load <file.mk>", "run a file in the session", (*session).load},
+		{":reset", ":reset", "drop all bindings of the session", (*session).reset},
+		{":restore", ":restore <file>", "reset the session and run the definitions in file", (*session).restore},
+		{":save", ":save <file>", "write the top-level lets run so far to file", (*session).save},
+		{":time", ":time <source>", "run source and print how long it took", (*session).time},
+		{":tokens", ":tokens [--json] <source>", "print the tokens of source", (*session).printTokens},
+		{":type", ":type <source>", "run source and print the type of its value", (*session).printType},
+	}
+}
+
+// runCommand : run a colon-command line
+func (s *session) runCommand(line string) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+
+	arg := ""
+	if len(parts) == 2 {
+		arg = strings.TrimSpace(parts[1])
+	}
+
+	for _, c := range commands {
+		if c.name == parts[0] {
+			c.run(s, arg)
+			return
+		}
+	}
+	s.printError(fmt.Sprintf("unknown command %s, see :help", parts[0]))
+}
+
+func (s *session) printHelp(string) {
+	for _, c := range commands {
+		fmt.Fprintf(s.out, "%-28s %s\n", c.usage, c.help)
+	}
+}
+
+func (s *session) printEnv(string) {
+	for _, name := range s.eng.Globals() {
+		val, _ := s.eng.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, val.Inspect())
+	}
+}
+
+func (s *session) reset(string) {
+	s.eng.Reset()
+	s.definitions = nil
+}
+
+func (s *session) save(path string) {
+	if path == "" {
+		s.printError("usage: :save <file>")
+		return
+	}
+
+	src := ""
+	for _, def := range s.definitions {
+		src += def + "\n"
+	}
+	if err := os.WriteFile(path, []byte(src), 0644); err != nil {
+		s.printError(err.Error())
+	}
+}
+
+func (s *session) restore(path string) {
+	if path == "" {
+		s.printError("usage: :restore <file>")
+		return
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		s.printError(err.Error())
+		return
+	}
+
+	s.reset("")
+	s.run(string(src))
+}
+
+func (s *session) load(path string) {
+	if path == "" {
+		s.printError("usage: :load <file.mk>")
+		return
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		s.printError(err.Error())
+		return
+	}
+
+	if evaluated, ok := s.run(string(src)); ok {
+		s.printResult(evaluated)
+	}
+}
+
+func (s *session) printType(src string) {
+	if evaluated, ok := s.run(src); ok && evaluated != nil {
+		io.WriteString(s.out, string(evaluated.Type())+"\n")
+	}
+}
+
+func (s *session) time(src string) {
+	start := time.Now()
+	evaluated, ok := s.run(src)
+	elapsed := time.Since(start)
+
+	if ok {
+		s.printResult(evaluated)
+	}
+	fmt.Fprintf(s.out, "time: %s\n", elapsed)
+}
+
+// parse : parse src on its own, printing any parse errors
+func (s *session) parse(src string) (*ast.Program, bool) {
+	p := parser.New(lexer.New(src))
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(s.errOut, p.Errors())
+		return nil, false
+	}
+	return program, true
+}
+
+// printAST : print the parsed tree of src, one node per line indented by
+// depth
+func (s *session) printAST(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+	ast.Walk(program, &treePrinter{out: s.out})
+}
+
+// treePrinter : an ast.Visitor printing each node it visits
+type treePrinter struct {
+	out   io.Writer
+	depth int
+}
+
+func (t *treePrinter) Visit(node ast.Node) ast.Visitor {
+	if node == nil {
+		return nil
+	}
+
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	detail := ""
+	switch node := node.(type) {
+	case *ast.Identifier:
+		detail = " " + node.Value
+	case *ast.IntegerLiteral:
+		detail = " " + node.Token.Literal
+	case *ast.Boolean:
+		detail = " " + node.Token.Literal
+	case *ast.PrefixExpression:
+		detail = " " + node.Operator
+	case *ast.InfixExpression:
+		detail = " " + node.Operator
+	case *ast.AttributeExpression:
+		detail = " " + node.Name
+	}
+
+	fmt.Fprintf(t.out, "%s%s%s\n", strings.Repeat("  ", t.depth), kind, detail)
+	return &treePrinter{out: t.out, depth: t.depth + 1}
+}
+
+// printBytecode : print the disassembled bytecode of src, compiled against
+// the session's bindings. The vm engine compiles it as it would run it; for
+// other engines the session's globals are numbered in name order.
+func (s *session) printBytecode(src string) {
+	program, ok := s.parse(src)
+	if !ok {
+		return
+	}
+
+	var bytecode *compiler.Bytecode
+	if vmEngine, ok := s.eng.(*engine.VM); ok {
+		var err error
+		if bytecode, err = vmEngine.Compile(program); err != nil {
+			s.printError(err.Error())
+			return
+		}
+	} else {
+		symbolTable := compiler.NewSymbolTable()
+		for _, name := range s.eng.Globals() {
+			symbolTable.Define(name)
+		}
+		comp := compiler.NewWithState(symbolTable, []object.Object{})
+		if err := comp.Compile(program); err != nil {
+			s.printError(err.Error())
+			return
+		}
+		bytecode = comp.Bytecode()
+	}
+
+	io.WriteString(s.out, compiler.Disassemble(bytecode))
+}
+
+// printTokens : print the tokens of src, as JSON when it starts with --json
+func (s *session) printTokens(src string) {
+	rest, asJSON := cutCommand(src, "--json")
+	if asJSON {
+		src = rest
+	}
+	lexer.Dump(s.out, src, asJSON)
+}
@@ -2,45 +2,227 @@ package repl
 
 import (
 	"bufio"
-	"fmt"
 	"io"
+	"os"
+	"strings"
 
-	"github.com/rockspore/monkey-interpreter/evaluator"
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/cst"
+	"github.com/rockspore/monkey-interpreter/editor"
+	"github.com/rockspore/monkey-interpreter/engine"
+	"github.com/rockspore/monkey-interpreter/highlight"
 	"github.com/rockspore/monkey-interpreter/lexer"
 	"github.com/rockspore/monkey-interpreter/object"
 	"github.com/rockspore/monkey-interpreter/parser"
+	"github.com/rockspore/monkey-interpreter/token"
 )
 
-// Start : entry point of the REPL
-func Start(in io.Reader, out io.Writer) {
-	const PROMPT = ">> "
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// PROMPT : printed before each line read from a terminal
+const PROMPT = ">> "
+
+// CONTINUATION_PROMPT : printed before the following lines of an incomplete
+// input
+const CONTINUATION_PROMPT = ".. "
+
+// ANSI colors of errors when colors are enabled
+const (
+	errorColor = "\x1b[31m"
+	colorReset = "\x1b[0m"
+)
+
+// session : the engine and streams of one REPL run
+type session struct {
+	eng         engine.Engine
+	out         io.Writer
+	errOut      io.Writer
+	interactive bool
+	historyFile string
+	color       bool
+
+	definitions []string // source of the top-level lets run so far
+}
+
+// Option : configures a REPL started by Start
+type Option func(*session)
+
+// WithErrors : write parse and runtime errors to w instead of the output
+func WithErrors(w io.Writer) Option {
+	return func(s *session) {
+		s.errOut = w
+	}
+}
+
+// WithPrompt : print prompts or not, regardless of whether the input is a
+// terminal
+func WithPrompt(prompt bool) Option {
+	return func(s *session) {
+		s.interactive = prompt
+	}
+}
+
+// WithHistory : keep the line editor's history in a file
+func WithHistory(path string) Option {
+	return func(s *session) {
+		s.historyFile = path
+	}
+}
+
+// WithColor : highlight input, results and errors with ANSI colors
+func WithColor(color bool) Option {
+	return func(s *session) {
+		s.color = color
+	}
+}
+
+// IsTerminal : report whether r is a terminal rather than a pipe or file
+func IsTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// Start : entry point of the REPL, running each line on the given engine.
+// Prompts are only printed when in is a terminal, which is then read with the
+// line editor.
+func Start(in io.Reader, out io.Writer, eng engine.Engine, opts ...Option) {
+	s := &session{eng: eng, out: out, errOut: out, interactive: IsTerminal(in)}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	var lines lineReader = &scannerReader{bufio.NewScanner(in), s}
+	if s.interactive && editor.Available(in) {
+		ed := editor.New(in, out)
+		ed.Complete = s.complete
+		if s.color {
+			ed.Highlight = highlight.ANSI
+		}
+		if s.historyFile != "" {
+			if err := ed.LoadHistory(s.historyFile); err != nil {
+				s.printError(err.Error())
+			}
+		}
+		lines = &editorReader{ed}
+	}
 
 	for {
-		fmt.Printf(PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, ok := s.readInput(lines)
+		if !ok {
 			return
 		}
 
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParseErrors(out, p.Errors())
+		if strings.HasPrefix(strings.TrimSpace(line), ":") {
+			s.runCommand(line)
 			continue
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		if evaluated, ok := s.run(line); ok {
+			s.printResult(evaluated)
+		}
+	}
+}
+
+// run : parse and run src on the session's engine, printing any error. The
+// top-level statements run one at a time, so that the lets which ran before
+// an error are still recorded, unless a top-level return may end the
+// program early
+func (s *session) run(src string) (object.Object, bool) {
+	l := lexer.New(src)
+	p := parser.New(l)
+
+	program := p.ParseProgram()
+	if len(p.Errors()) != 0 {
+		printParseErrors(s.errOut, p.Errors())
+		return nil, false
+	}
+
+	if hasTopLevelReturn(program) {
+		evaluated, err := s.eng.Run(program)
+		if err != nil {
+			s.printError(err.Error())
+			return nil, false
+		}
+		s.recordDefinitions(src, program, len(program.Statements))
+		return evaluated, true
+	}
+
+	var evaluated object.Object
+	for i, stmt := range program.Statements {
+		var err error
+		evaluated, err = s.eng.Run(&ast.Program{Statements: []ast.Statement{stmt}})
+		if err != nil {
+			s.recordDefinitions(src, program, i)
+			s.printError(err.Error())
+			return nil, false
+		}
+	}
+
+	s.recordDefinitions(src, program, len(program.Statements))
+	return evaluated, true
+}
+
+// hasTopLevelReturn : report whether a return outside any function may end
+// the program
+func hasTopLevelReturn(program *ast.Program) bool {
+	found := false
+	ast.Inspect(program, func(node ast.Node) bool {
+		switch node.(type) {
+		case *ast.ReturnStatement:
+			found = true
+		case *ast.FunctionLiteral:
+			return false
+		}
+		return !found
+	})
+	return found
+}
+
+// recordDefinitions : remember the source of the top-level lets among the
+// first n statements of a program, which ran, for :save
+func (s *session) recordDefinitions(src string, program *ast.Program, n int) {
+	var lets []int
+	for i, stmt := range program.Statements[:n] {
+		if _, ok := stmt.(*ast.LetStatement); ok {
+			lets = append(lets, i)
+		}
+	}
+	if len(lets) == 0 {
+		return
+	}
+
+	tree, err := cst.Parse(src)
+	if err != nil {
+		return
+	}
+	for _, i := range lets {
+		text, _ := tree.Text(tree.Program.Statements[i])
+		if !strings.HasSuffix(text, ";") {
+			text += ";"
+		}
+		s.definitions = append(s.definitions, text)
+	}
+}
+
+func (s *session) printResult(obj object.Object) {
+	if obj != nil {
+		result := obj.Inspect()
+		if s.color {
+			result = highlight.ANSI(result)
 		}
+		io.WriteString(s.out, result)
+		io.WriteString(s.out, "\n")
+	}
+}
 
+func (s *session) printError(msg string) {
+	if s.color {
+		io.WriteString(s.errOut, errorColor+"ERROR: "+msg+colorReset+"\n")
+		return
 	}
+	io.WriteString(s.errOut, "ERROR: "+msg+"\n")
 }
 
 func printParseErrors(out io.Writer, errors []string) {
@@ -48,3 +230,141 @@ func printParseErrors(out io.Writer, errors []string) {
 		io.WriteString(out, "\t"+msg+"\n")
 	}
 }
+
+// lineReader : reads one line of input after showing a prompt
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// scannerReader : reads lines from a pipe or file, or a terminal the line
+// editor cannot drive
+type scannerReader struct {
+	scanner *bufio.Scanner
+	s       *session
+}
+
+func (r *scannerReader) readLine(prompt string) (string, error) {
+	if r.s.interactive {
+		io.WriteString(r.s.out, prompt)
+	}
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	return r.scanner.Text(), nil
+}
+
+// editorReader : reads lines from a terminal with the line editor, adding
+// them to its history
+type editorReader struct {
+	ed *editor.Editor
+}
+
+func (r *editorReader) readLine(prompt string) (string, error) {
+	line, err := r.ed.ReadLine(prompt)
+	if err == nil {
+		r.ed.AddHistory(line)
+	}
+	return line, err
+}
+
+// readInput : read lines until they form a complete input, returning false
+// once the input is exhausted
+func (s *session) readInput(lines lineReader) (string, bool) {
+	prompt := PROMPT
+	var read []string
+
+	for {
+		line, err := lines.readLine(prompt)
+		if err == editor.ErrInterrupted {
+			// drop the input so far
+			prompt = PROMPT
+			read = nil
+			continue
+		}
+		if err != nil {
+			// run what was read so far, reporting its errors
+			return strings.Join(read, "\n"), len(read) > 0
+		}
+
+		read = append(read, line)
+		if len(read) == 1 && strings.HasPrefix(strings.TrimSpace(read[0]), ":") {
+			return read[0], true
+		}
+
+		input := strings.Join(read, "\n")
+		if !incomplete(input) {
+			return input, true
+		}
+		prompt = CONTINUATION_PROMPT
+	}
+}
+
+// complete : return the completions of a word for the line editor: command
+// names, keywords and the session's globals
+func (s *session) complete(word string) []string {
+	if strings.HasPrefix(word, ":") {
+		names := []string{}
+		for _, c := range commands {
+			names = append(names, c.name)
+		}
+		return names
+	}
+	return append(token.Keywords(), s.eng.Globals()...)
+}
+
+// continuing : tokens that cannot end a statement
+var continuing = map[token.TokenType]bool{
+	token.ASSIGN:   true,
+	token.PLUS:     true,
+	token.MINUS:    true,
+	token.BANG:     true,
+	token.ASTERISK: true,
+	token.SLASH:    true,
+	token.LT:       true,
+	token.LE:       true,
+	token.GT:       true,
+	token.GE:       true,
+	token.EQ:       true,
+	token.NEQ:      true,
+	token.COMMA:    true,
+	token.DOT:      true,
+	token.LET:      true,
+	token.RETURN:   true,
+	token.FUNCTION: true,
+	token.IF:       true,
+	token.ELSE:     true,
+	token.WHILE:    true,
+}
+
+// incomplete : report whether src has unclosed parentheses or braces, or
+// ends with a token that must be followed by more input
+func incomplete(src string) bool {
+	depth := 0
+	last := token.Token{Type: token.EOF}
+
+	for _, tok := range lexer.Tokens(src) {
+		switch tok.Type {
+		case token.LPAREN, token.LBRACE:
+			depth++
+		case token.RPAREN, token.RBRACE:
+			depth--
+		case token.EOF:
+			continue
+		}
+		last = tok
+	}
+
+	return depth > 0 || continuing[last.Type]
+}
+
+// cutCommand : return the argument of a colon-command line
+func cutCommand(line string, command string) (string, bool) {
+	line = strings.TrimSpace(line)
+	if line != command && !strings.HasPrefix(line, command+" ") {
+		return "", false
+	}
+	return strings.TrimSpace(line[len(command):]), true
+}
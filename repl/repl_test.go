@@ -0,0 +1,251 @@
+package repl
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/engine"
+	"github.com/rockspore/monkey-interpreter/highlight"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+func runSession(t *testing.T, input string, opts ...Option) (string, string) {
+	t.Helper()
+
+	eng, err := engine.New("eval")
+	if err != nil {
+		t.Fatalf("engine.New returned error: %s", err)
+	}
+
+	var out, errOut bytes.Buffer
+	opts = append([]Option{WithErrors(&errOut)}, opts...)
+	Start(strings.NewReader(input), &out, eng, opts...)
+	return out.String(), errOut.String()
+}
+
+func TestNonInteractive(t *testing.T) {
+	out, errOut := runSession(t, "let a = 2;\na * 3\nb\nlet = 1\n")
+
+	if out != "6\n" {
+		t.Errorf("output wrong. expected=%q, got=%q", "6\n", out)
+	}
+
+	expectedErr := "ERROR: identifier not found: b\n" +
+		"\texpected next token to be 'IDENT'. got '=' instead\n" +
+		"\tno prefix parse function for = found.\n"
+	if errOut != expectedErr {
+		t.Errorf("errors wrong. expected=%q, got=%q", expectedErr, errOut)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	out, _ := runSession(t, "1\n", WithPrompt(true))
+
+	expected := PROMPT + "1\n" + PROMPT
+	if out != expected {
+		t.Errorf("output wrong. expected=%q, got=%q", expected, out)
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := `let add = fn(a, b) {
+  a +
+    b
+};
+add(
+  1,
+  2
+)
+if (true) { 1 } else { 2 }
+`
+
+	out, errOut := runSession(t, input, WithPrompt(true))
+
+	expected := PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT +
+		PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + CONTINUATION_PROMPT + "3\n" +
+		PROMPT + "1\n" + PROMPT
+	if out != expected {
+		t.Errorf("output wrong. expected=%q, got=%q", expected, out)
+	}
+	if errOut != "" {
+		t.Errorf("unexpected errors: %q", errOut)
+	}
+}
+
+func TestIncompleteInputAtEnd(t *testing.T) {
+	out, errOut := runSession(t, "let f = fn(x) {\n  x\n")
+
+	if out != "" {
+		t.Errorf("unexpected output: %q", out)
+	}
+	expected := "\texpected next token to be '}'. got 'EOF' instead\n"
+	if errOut != expected {
+		t.Errorf("errors wrong. expected=%q, got=%q", expected, errOut)
+	}
+}
+
+func TestIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1 + 2", false},
+		{"1 +", true},
+		{"let x =", true},
+		{"f(1,", true},
+		{"fn(x) {", true},
+		{"fn(x) { x }", false},
+		{"if (x) { 1 } else", true},
+		{"(1 + (2)", true},
+		{"1)", false},
+		{"x // a comment (", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := incomplete(tt.input); got != tt.expected {
+			t.Errorf("incomplete(%q) wrong. expected=%t, got=%t", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "lib.mk")
+	if err := os.WriteFile(file, []byte("let double = fn(x) { x * 2 };\ndouble(4)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		input       string
+		expected    string
+		expectedErr string
+	}{
+		{":env", "", ""},
+		{"let a = 1;\nlet b = true;\n:env", "a = 1\nb = true\n", ""},
+		{":load " + file + "\ndouble(21)", "8\n42\n", ""},
+		{":load", "", "ERROR: usage: :load <file.mk>\n"},
+		{"let a = 1;\n:reset\na", "", "ERROR: identifier not found: a\n"},
+		{":type 1 < 2\n:type fn() {}\n:type let x = 1", "BOOLEAN\nFUNCTION\n", ""},
+		{":type y", "", "ERROR: identifier not found: y\n"},
+		{":ast -a + f(1)", "Program\n  ExpressionStatement\n    InfixExpression +\n" +
+			"      PrefixExpression -\n        Identifier a\n      CallExpression\n" +
+			"        Identifier f\n        IntegerLiteral 1\n", ""},
+		{":ast let = 1", "", "\texpected next token to be 'IDENT'. got '=' instead\n" +
+			"\tno prefix parse function for = found.\n"},
+		{":tokens 1", "1:1     INT       \"1\"\n1:2     EOF       \"\"\n", ""},
+		{":what", "", "ERROR: unknown command :what, see :help\n"},
+	}
+
+	for _, tt := range tests {
+		out, errOut := runSession(t, tt.input)
+		if out != tt.expected {
+			t.Errorf("%q: output wrong. expected=%q, got=%q", tt.input, tt.expected, out)
+		}
+		if errOut != tt.expectedErr {
+			t.Errorf("%q: errors wrong. expected=%q, got=%q", tt.input, tt.expectedErr, errOut)
+		}
+	}
+}
+
+func TestTimeAndHelp(t *testing.T) {
+	out, _ := runSession(t, ":time 6 * 7")
+	if !strings.HasPrefix(out, "42\ntime: ") {
+		t.Errorf(":time output wrong. got=%q", out)
+	}
+
+	out, _ = runSession(t, ":help")
+	for _, c := range commands {
+		if !strings.Contains(out, c.usage) {
+			t.Errorf(":help does not list %s. got=%q", c.name, out)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	eng, _ := engine.New("eval")
+	s := &session{eng: eng}
+	eng.Set("result", &object.Integer{Value: 1})
+
+	words := strings.Join(s.complete("re"), " ")
+	for _, expected := range []string{"return", "result", "let", "while"} {
+		if !strings.Contains(words, expected) {
+			t.Errorf("completions missing %q. got=%q", expected, words)
+		}
+	}
+
+	commandWords := strings.Join(s.complete(":"), " ")
+	if !strings.Contains(commandWords, ":env") || strings.Contains(commandWords, "let") {
+		t.Errorf("command completions wrong. got=%q", commandWords)
+	}
+}
+
+func TestColor(t *testing.T) {
+	out, errOut := runSession(t, "fn(x) { x }\ny", WithColor(true))
+
+	expected := highlight.ANSI("fn(x) {\nx\n}") + "\n"
+	if out != expected {
+		t.Errorf("output wrong. expected=%q, got=%q", expected, out)
+	}
+	if errOut != errorColor+"ERROR: identifier not found: y"+colorReset+"\n" {
+		t.Errorf("errors wrong. got=%q", errOut)
+	}
+}
+
+func TestSaveRestore(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "session.mk")
+
+	input := `let a = 1
+let add = fn(x, y) {
+  x + y // sum
+};
+add(a, 2)
+let b = c;
+let b = add(a, 10); let c = b * 2
+let d = 5; let e = d + 1; e + f; let g = 7
+:save ` + file
+
+	out, errOut := runSession(t, input)
+	if out != "3\n" || errOut != "ERROR: identifier not found: c\nERROR: identifier not found: f\n" {
+		t.Fatalf("session output wrong. out=%q, errors=%q", out, errOut)
+	}
+
+	expected := "let a = 1;\nlet add = fn(x, y) {\n  x + y // sum\n};\nlet b = add(a, 10);\nlet c = b * 2;\nlet d = 5;\nlet e = d + 1;\n"
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != expected {
+		t.Errorf("saved session wrong.\nexpected=%q\ngot=%q", expected, data)
+	}
+
+	out, errOut = runSession(t, "let stale = 1;\n:restore "+file+"\nc\ne\nstale")
+	if out != "22\n6\n" || errOut != "ERROR: identifier not found: stale\n" {
+		t.Errorf("restored session wrong. out=%q, errors=%q", out, errOut)
+	}
+}
+
+func TestBytecodeAgainstSession(t *testing.T) {
+	for _, name := range engine.Names() {
+		eng, err := engine.New(name)
+		if err != nil {
+			t.Fatalf("engine.New returned error: %s", err)
+		}
+
+		var out, errOut bytes.Buffer
+		input := "let a = 1;\nlet b = 2;\n:bytecode b\n:bytecode c"
+		Start(strings.NewReader(input), &out, eng, WithErrors(&errOut))
+
+		if !strings.Contains(out.String(), "OpGetGlobal 1\n") {
+			t.Errorf("[%s] b not compiled as the session's global. got=%q", name, out.String())
+		}
+		if errOut.String() != "ERROR: identifier not found: c\n" {
+			t.Errorf("[%s] errors wrong. got=%q", name, errOut.String())
+		}
+		if _, ok := eng.Get("b"); !ok || len(eng.Globals()) != 2 {
+			t.Errorf("[%s] :bytecode changed the session. globals=%v", name, eng.Globals())
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package resolver
+
+import (
+	"github.com/rockspore/monkey-interpreter/ast"
+)
+
+// scope : the slots of one function, parameters first followed by every name
+// bound with let anywhere in its body outside nested functions
+type scope struct {
+	slots map[string]int
+	names []string
+}
+
+func (s *scope) define(name string) {
+	if _, ok := s.slots[name]; ok {
+		return
+	}
+	s.slots[name] = len(s.names)
+	s.names = append(s.names, name)
+}
+
+// resolver : visits the ast keeping the stack of enclosing function scopes
+type resolver struct {
+	scopes []*scope
+}
+
+// Resolve : bind every identifier local to a function to its (depth, slot)
+// and record the slot layout on each function literal. Names outside any
+// function stay unresolved and are looked up by name at runtime.
+func Resolve(node ast.Node) {
+	ast.Walk(node, &resolver{})
+}
+
+// Visit : resolve identifiers, walking function literals with a resolver
+// for their own scope
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch node := node.(type) {
+	case *ast.Identifier:
+		r.resolveIdentifier(node)
+
+	case *ast.FunctionLiteral:
+		s := &scope{slots: map[string]int{}, names: []string{}}
+		for _, p := range node.Parameters {
+			s.define(p.Value)
+		}
+		// let bindings are hoisted so that a name refers to the same slot
+		// before and after its let statement runs, e.g. in loops
+		declareLets(s, node.Body)
+		node.Locals = s.names
+
+		scopes := append(r.scopes[:len(r.scopes):len(r.scopes)], s)
+		return &resolver{scopes: scopes}
+	}
+
+	return r
+}
+
+func (r *resolver) resolveIdentifier(ident *ast.Identifier) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if slot, ok := r.scopes[i].slots[ident.Value]; ok {
+			ident.Resolved = true
+			ident.Depth = len(r.scopes) - 1 - i
+			ident.Slot = slot
+			return
+		}
+	}
+	ident.Resolved = false
+}
+
+// declareLets : define the names bound by let statements in a function body,
+// without descending into nested functions
+func declareLets(s *scope, body *ast.BlockStatement) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			s.define(node.Name.Value)
+		}
+		return true
+	})
+}
@@ -0,0 +1,64 @@
+package resolver
+
+import (
+	"testing"
+
+	"github.com/rockspore/monkey-interpreter/ast"
+	"github.com/rockspore/monkey-interpreter/lexer"
+	"github.com/rockspore/monkey-interpreter/parser"
+)
+
+func TestResolve(t *testing.T) {
+	input := `
+let g = 1;
+let outer = fn(a, b) {
+	let c = a;
+	if (b) { let d = g; }
+	fn(e) { a + c + e + d + g }
+};`
+
+	program := parser.New(lexer.New(input)).ParseProgram()
+	Resolve(program)
+
+	type binding struct {
+		resolved    bool
+		depth, slot int
+	}
+	expected := map[string][]binding{
+		"g": {{false, 0, 0}, {false, 0, 0}, {false, 0, 0}},
+		"a": {{true, 0, 0}, {true, 0, 0}, {true, 1, 0}},
+		"b": {{true, 0, 1}, {true, 0, 1}},
+		"c": {{true, 0, 2}, {true, 1, 2}},
+		"d": {{true, 0, 3}, {true, 1, 3}},
+		"e": {{true, 0, 0}, {true, 0, 0}},
+	}
+
+	actual := map[string][]binding{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		if ident, ok := node.(*ast.Identifier); ok {
+			actual[ident.Value] = append(actual[ident.Value],
+				binding{ident.Resolved, ident.Depth, ident.Slot})
+		}
+		return true
+	})
+
+	for name, want := range expected {
+		got := actual[name]
+		if len(got) != len(want) {
+			t.Errorf("%s: wrong number of occurrences. want=%d, got=%d",
+				name, len(want), len(got))
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%s[%d]: wrong binding. want=%+v, got=%+v",
+					name, i, want[i], got[i])
+			}
+		}
+	}
+
+	outer := program.Statements[1].(*ast.LetStatement).Value.(*ast.FunctionLiteral)
+	if len(outer.Locals) != 4 || outer.Locals[3] != "d" {
+		t.Errorf("wrong locals of outer function. got=%v", outer.Locals)
+	}
+}
@@ -1,12 +1,34 @@
 package token
 
+import "sort"
+
 type TokenType string
 
 type Token struct {
 	Type    TokenType
 	Literal string
+	Line    int // source line the token starts on, starting at 1
+	Column  int // byte offset of the token in its line, starting at 1
+
+	// Leading : the whitespace and comments before the token, only kept by
+	// lexers created with lexer.NewWithTrivia
+	Leading []Trivia
+}
+
+// TriviaKind : the kind of source text skipped between tokens
+type TriviaKind string
+
+// Trivia : a run of whitespace or a comment, kept verbatim
+type Trivia struct {
+	Kind TriviaKind
+	Text string
 }
 
+const (
+	WHITESPACE TriviaKind = "WHITESPACE"
+	COMMENT    TriviaKind = "COMMENT"
+)
+
 const (
 	ILLEGAL = "ILLEGAL"
 	EOF     = "EOF"
@@ -35,6 +57,7 @@ const (
 	// Delimiters
 	COMMA     = ","
 	SEMICOLON = ";"
+	DOT       = "."
 
 	LPAREN = "("
 	RPAREN = ")"
@@ -65,6 +88,16 @@ var keywords = map[string]TokenType{
 	"return": RETURN,
 }
 
+// Keywords : return the sorted keywords of the language
+func Keywords() []string {
+	words := []string{}
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
+
 func LookupIdent(ident string) TokenType {
 	if tok, ok := keywords[ident]; ok {
 		return tok
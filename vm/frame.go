@@ -0,0 +1,35 @@
+package vm
+
+import (
+	"fmt"
+
+	"github.com/rockspore/monkey-interpreter/code"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+// Frame : the call frame of a closure being executed
+type Frame struct {
+	cl          *object.Closure
+	ip          int
+	basePointer int
+}
+
+// NewFrame : create a new frame whose locals start at basePointer
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{cl: cl, ip: -1, basePointer: basePointer}
+}
+
+// Instructions : return the instructions of the frame's function
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
+
+// localName : return the name of a local slot, or its index when the
+// function carries no names
+func (f *Frame) localName(index int) string {
+	names := f.cl.Fn.LocalNames
+	if index < len(names) && names[index] != "" {
+		return names[index]
+	}
+	return fmt.Sprintf("local %d", index)
+}
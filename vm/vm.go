@@ -0,0 +1,578 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/rockspore/monkey-interpreter/code"
+	"github.com/rockspore/monkey-interpreter/compiler"
+	"github.com/rockspore/monkey-interpreter/object"
+)
+
+// StackSize : initial number of stack slots, grown on demand
+const StackSize = 2048
+
+// GlobalsSize : number of addressable global bindings
+const GlobalsSize = 65536
+
+// DefaultMaxCallDepth : the maximum number of nested function calls before
+// execution is aborted with a stack overflow error, unless the vm sets its own
+const DefaultMaxCallDepth = 10000
+
+var (
+	True  = &object.Boolean{Value: true}
+	False = &object.Boolean{Value: false}
+	Null  = &object.Null{}
+)
+
+// VM : the stack-based virtual machine executing compiled bytecode
+type VM struct {
+	constants []object.Object
+
+	stack []object.Object
+	sp    int // always points to the next free slot. Top of stack is stack[sp-1]
+
+	lastPopped object.Object // value of the last statement, nil after a let
+
+	globals     []object.Object
+	globalNames []string
+
+	frames       []*Frame
+	framesIndex  int
+	maxCallDepth int
+
+	ctx      context.Context
+	maxSteps int
+	steps    int
+}
+
+// New : create a new vm running the given bytecode
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+
+	return &VM{
+		constants: bytecode.Constants,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.Globals,
+
+		frames:       []*Frame{mainFrame},
+		framesIndex:  1,
+		maxCallDepth: DefaultMaxCallDepth,
+
+		ctx: context.Background(),
+	}
+}
+
+// NewWithGlobalsStore : create a new vm sharing the globals of an earlier
+// run, e.g. between REPL lines
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// SetMaxCallDepth : limit the number of nested function calls, 0 meaning
+// DefaultMaxCallDepth
+func (vm *VM) SetMaxCallDepth(depth int) {
+	if depth <= 0 {
+		depth = DefaultMaxCallDepth
+	}
+	vm.maxCallDepth = depth
+}
+
+// LastPoppedStackElem : return the value of the last evaluated statement,
+// or nil when it had none, e.g. a let statement
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex < len(vm.frames) {
+		vm.frames[vm.framesIndex] = f
+	} else {
+		vm.frames = append(vm.frames, f)
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+// Run : execute the bytecode until the main program finishes
+func (vm *VM) Run() error {
+	return vm.RunContext(context.Background(), 0)
+}
+
+// RunContext : execute the bytecode like Run, aborting with an error once ctx
+// is done or more than maxSteps instructions have been executed (0 means no
+// limit). Limits are checked on every jump and function call.
+func (vm *VM) RunContext(ctx context.Context, maxSteps int) error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+
+	vm.ctx = ctx
+	vm.maxSteps = maxSteps
+	vm.steps = 0
+	vm.lastPopped = nil
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+		vm.steps++
+
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual,
+			code.OpGreaterThan, code.OpGreaterEqual,
+			code.OpLessThan, code.OpLessEqual:
+			if err := vm.executeBinaryOperation(op); err != nil {
+				return err
+			}
+
+		case code.OpTrue:
+			vm.push(True)
+
+		case code.OpFalse:
+			vm.push(False)
+
+		case code.OpNull:
+			vm.push(Null)
+
+		case code.OpBang:
+			vm.push(nativeBoolToBooleanObject(!isTruthy(vm.pop())))
+
+		case code.OpMinus:
+			if err := vm.executeMinusOperator(); err != nil {
+				return err
+			}
+
+		case code.OpJump:
+			if err := vm.checkLimits(); err != nil {
+				return err
+			}
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			condition := vm.pop()
+			if !isTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			vm.globals[globalIndex] = vm.pop()
+			vm.lastPopped = nil
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			global := vm.globals[globalIndex]
+			if global == nil {
+				// defined by a let that failed or has not run yet
+				return fmt.Errorf("identifier not found: %s", vm.globalName(int(globalIndex)))
+			}
+			vm.push(global)
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			vm.stack[frame.basePointer+int(localIndex)] = vm.pop()
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			frame := vm.currentFrame()
+			local := vm.stack[frame.basePointer+int(localIndex)]
+			if local == nil {
+				// bound by a let that has not run in this call
+				return fmt.Errorf("identifier not found: %s", frame.localName(int(localIndex)))
+			}
+			vm.push(local)
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			currentClosure := vm.currentFrame().cl
+			vm.push(currentClosure.Free[freeIndex])
+
+		case code.OpCurrentClosure:
+			vm.push(vm.currentFrame().cl)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpGetAttr:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			obj := vm.pop()
+			if err := vm.pushHostResult(object.GetAttr(obj, vm.name(nameIndex))); err != nil {
+				return err
+			}
+
+		case code.OpSetAttr:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+
+			val := vm.pop()
+			obj := vm.pop()
+			if err := object.SetAttr(obj, vm.name(nameIndex), val); err != nil {
+				return err.AsError()
+			}
+			vm.push(val)
+
+		case code.OpCallMethod:
+			nameIndex := code.ReadUint16(ins[ip+1:])
+			numArgs := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+
+			if err := vm.callMethod(vm.name(nameIndex), int(numArgs)); err != nil {
+				return err
+			}
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+
+			if vm.framesIndex == 1 {
+				// a top-level return ends the program with its value as result
+				vm.lastPopped = returnValue
+				return nil
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			vm.push(returnValue)
+
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+
+			vm.push(Null)
+
+		default:
+			def, err := code.Lookup(byte(op))
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("unhandled opcode %s", def.Name)
+		}
+	}
+
+	return nil
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	if err := vm.checkLimits(); err != nil {
+		return err
+	}
+
+	switch callee := vm.stack[vm.sp-1-numArgs].(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return fmt.Errorf("not a function: %s", callee.Type())
+	}
+}
+
+// checkLimits : return an error once the context is done or the step budget
+// is used up, with the messages of the evaluator
+func (vm *VM) checkLimits() error {
+	if vm.maxSteps > 0 && vm.steps > vm.maxSteps {
+		return &object.AbortError{Message: fmt.Sprintf("step budget exceeded: %d", vm.maxSteps)}
+	}
+	if err := vm.ctx.Err(); err != nil {
+		return &object.AbortError{Message: fmt.Sprintf("evaluation cancelled: %s", err), Err: err}
+	}
+	return nil
+}
+
+// callBuiltin : call a host function and replace the callee and arguments
+// with its result, mapping null and booleans to the vm's singletons
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	return vm.pushHostResult(result)
+}
+
+// callMethod : call a method of the receiver below the arguments on the
+// stack, or else call a function held in one of its attributes
+func (vm *VM) callMethod(name string, numArgs int) error {
+	receiver := vm.stack[vm.sp-1-numArgs]
+	args := make([]object.Object, numArgs)
+	copy(args, vm.stack[vm.sp-numArgs:vm.sp])
+
+	result, called := object.LookupMethod(receiver, name, args)
+	if called {
+		vm.sp = vm.sp - numArgs - 1
+		return vm.pushHostResult(result)
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return errObj.AsError()
+	}
+
+	vm.stack[vm.sp-1-numArgs] = result
+	return vm.executeCall(numArgs)
+}
+
+// pushHostResult : push the result of a host function or method, mapping
+// null and booleans to the vm's singletons and error objects to errors
+func (vm *VM) pushHostResult(result object.Object) error {
+	switch result := result.(type) {
+	case nil, *object.Null:
+		vm.push(Null)
+	case *object.Boolean:
+		vm.push(nativeBoolToBooleanObject(result.Value))
+	case *object.Error:
+		return result.AsError()
+	default:
+		vm.push(result)
+	}
+	return nil
+}
+
+// globalName : return the name of a global slot, as far as the bytecode
+// tells
+func (vm *VM) globalName(index int) string {
+	if index < len(vm.globalNames) && vm.globalNames[index] != "" {
+		return vm.globalNames[index]
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+// name : return the attribute name held by a constant
+func (vm *VM) name(index uint16) string {
+	return vm.constants[index].(*object.String).Value
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+
+	if numArgs != cl.Fn.NumParameters {
+		return fmt.Errorf("wrong number of arguments: want=%d, got=%d",
+			cl.Fn.NumParameters, numArgs)
+	}
+
+	depth := vm.framesIndex
+	if depth > vm.maxCallDepth {
+		return fmt.Errorf("stack overflow: depth %d", depth)
+	}
+
+	frame := NewFrame(cl, vm.sp-numArgs)
+	vm.pushFrame(frame)
+
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	vm.ensureStack(vm.sp)
+
+	// clear the locals besides the arguments, which may hold values left by
+	// an earlier call
+	for i := frame.basePointer + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+
+	return nil
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+
+	vm.push(&object.Closure{Fn: function, Free: free})
+	return nil
+}
+
+func (vm *VM) executeBinaryOperation(op code.Opcode) error {
+	right := vm.pop()
+	left := vm.pop()
+
+	leftType := left.Type()
+	rightType := right.Type()
+
+	switch {
+	case leftType == object.IntegerOBJ && rightType == object.IntegerOBJ:
+		return vm.executeBinaryIntegerOperation(op, left, right)
+	case op == code.OpEqual:
+		vm.push(nativeBoolToBooleanObject(left == right))
+		return nil
+	case op == code.OpNotEqual:
+		vm.push(nativeBoolToBooleanObject(left != right))
+		return nil
+	case leftType != rightType:
+		return fmt.Errorf("type mismatch: %s %s %s",
+			leftType, operatorString(op), rightType)
+	default:
+		return fmt.Errorf("unknown operator: %s %s %s",
+			leftType, operatorString(op), rightType)
+	}
+}
+
+func (vm *VM) executeBinaryIntegerOperation(op code.Opcode, left, right object.Object) error {
+	leftValue := left.(*object.Integer).Value
+	rightValue := right.(*object.Integer).Value
+
+	switch op {
+	case code.OpAdd:
+		vm.push(&object.Integer{Value: leftValue + rightValue})
+	case code.OpSub:
+		vm.push(&object.Integer{Value: leftValue - rightValue})
+	case code.OpMul:
+		vm.push(&object.Integer{Value: leftValue * rightValue})
+	case code.OpDiv:
+		if rightValue == 0 {
+			return fmt.Errorf("division by zero")
+		}
+		vm.push(&object.Integer{Value: leftValue / rightValue})
+	case code.OpEqual:
+		vm.push(nativeBoolToBooleanObject(leftValue == rightValue))
+	case code.OpNotEqual:
+		vm.push(nativeBoolToBooleanObject(leftValue != rightValue))
+	case code.OpGreaterThan:
+		vm.push(nativeBoolToBooleanObject(leftValue > rightValue))
+	case code.OpGreaterEqual:
+		vm.push(nativeBoolToBooleanObject(leftValue >= rightValue))
+	case code.OpLessThan:
+		vm.push(nativeBoolToBooleanObject(leftValue < rightValue))
+	case code.OpLessEqual:
+		vm.push(nativeBoolToBooleanObject(leftValue <= rightValue))
+	default:
+		return fmt.Errorf("unknown integer operator: %d", op)
+	}
+
+	return nil
+}
+
+func (vm *VM) executeMinusOperator() error {
+	operand := vm.pop()
+
+	if operand.Type() != object.IntegerOBJ {
+		return fmt.Errorf("unknown operator: -%s", operand.Type())
+	}
+
+	value := operand.(*object.Integer).Value
+	vm.push(&object.Integer{Value: -value})
+	return nil
+}
+
+func (vm *VM) push(o object.Object) {
+	vm.ensureStack(vm.sp + 1)
+	vm.stack[vm.sp] = o
+	vm.sp++
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// ensureStack : grow the stack so that it holds at least n slots
+func (vm *VM) ensureStack(n int) {
+	for n > len(vm.stack) {
+		vm.stack = append(vm.stack, make([]object.Object, len(vm.stack))...)
+	}
+}
+
+// operatorString : return the source form of a binary operator opcode, for
+// error messages matching the evaluator's
+func operatorString(op code.Opcode) string {
+	switch op {
+	case code.OpAdd:
+		return "+"
+	case code.OpSub:
+		return "-"
+	case code.OpMul:
+		return "*"
+	case code.OpDiv:
+		return "/"
+	case code.OpGreaterThan:
+		return ">"
+	case code.OpGreaterEqual:
+		return ">="
+	case code.OpLessThan:
+		return "<"
+	case code.OpLessEqual:
+		return "<="
+	}
+	return fmt.Sprintf("%d", op)
+}
+
+func nativeBoolToBooleanObject(input bool) *object.Boolean {
+	if input {
+		return True
+	}
+	return False
+}
+
+func isTruthy(obj object.Object) bool {
+	switch obj := obj.(type) {
+	case *object.Boolean:
+		return obj.Value
+	case *object.Null:
+		return false
+	default:
+		return true
+	}
+}